@@ -0,0 +1,156 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// cacheDir holds the on-disk compile cache `gecko watch` (and, incidentally,
+// `gecko build`) consult before shelling out to the assembler.
+const cacheDir = ".gecko-cache"
+
+// loadCompileCache returns the cached machine code for file if an entry
+// exists under its current cacheKey, i.e. the file, everything it
+// .includes, baseAddress, and linkerSymbols are all unchanged since the
+// bytes were cached.
+func loadCompileCache(file string, baseAddress uint32) ([]byte, bool) {
+	key, ok := cacheKey(file, baseAddress)
+	if !ok {
+		return nil, false
+	}
+
+	contents, err := ioutil.ReadFile(filepath.Join(cacheDir, key))
+	if err != nil {
+		return nil, false
+	}
+
+	return contents, true
+}
+
+// storeCompileCache saves instructions under file's current cacheKey so the
+// next compile of an unchanged file can skip straight to loadCompileCache.
+func storeCompileCache(file string, baseAddress uint32, instructions []byte) {
+	key, ok := cacheKey(file, baseAddress)
+	if !ok {
+		return
+	}
+
+	if err := os.MkdirAll(cacheDir, 0755); err != nil {
+		return
+	}
+
+	ioutil.WriteFile(filepath.Join(cacheDir, key), instructions, 0644)
+}
+
+// cacheKey derives file's cache key from the selected assembler backend,
+// its path, mtime, size, baseAddress, the project's current linkerSymbols,
+// and the sha256 of its contents plus the contents of every file it
+// .includes (transitively). Any of those changing changes the key, so a
+// stale cache entry is simply never looked up again rather than needing to
+// be invalidated. assembler is part of the key so switching
+// --assembler=native/external recompiles instead of silently reusing the
+// other backend's cached output.
+func cacheKey(file string, baseAddress uint32) (string, bool) {
+	info, err := os.Stat(file)
+	if err != nil {
+		return "", false
+	}
+
+	contents, err := ioutil.ReadFile(file)
+	if err != nil {
+		return "", false
+	}
+
+	h := sha256.New()
+	fmt.Fprintf(h, "%s:%s:%d:%d:%08X:", assembler, file, info.ModTime().UnixNano(), info.Size(), baseAddress)
+	for _, name := range sortedSymbolNames(linkerSymbols) {
+		fmt.Fprintf(h, "%s=%08X;", name, linkerSymbols[name])
+	}
+	h.Write(contents)
+
+	seen := map[string]bool{file: true}
+	for _, inc := range resolveIncludes(file, seen) {
+		incContents, err := ioutil.ReadFile(inc)
+		if err != nil {
+			continue
+		}
+		h.Write(incContents)
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), true
+}
+
+// sortedSymbolNames returns symbols' keys sorted, so cacheKey hashes them
+// in a deterministic order regardless of map iteration order.
+func sortedSymbolNames(symbols map[string]uint32) []string {
+	names := make([]string, 0, len(symbols))
+	for name := range symbols {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// resolveIncludes returns every file pulled in by file's `.include`
+// directives, transitively, in the order they're encountered. seen guards
+// against include cycles and is shared across the recursion.
+func resolveIncludes(file string, seen map[string]bool) []string {
+	contents, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil
+	}
+
+	var includes []string
+	for _, line := range strings.Split(string(contents), "\n") {
+		name := includeTarget(line)
+		if name == "" {
+			continue
+		}
+
+		incPath := filepath.Join(filepath.Dir(file), name)
+		if seen[incPath] {
+			continue
+		}
+		seen[incPath] = true
+
+		includes = append(includes, incPath)
+		includes = append(includes, resolveIncludes(incPath, seen)...)
+	}
+
+	return includes
+}
+
+// includeTarget returns the quoted/angle-bracketed filename of a `.include`
+// directive line, or "" if line isn't one.
+func includeTarget(line string) string {
+	line = strings.TrimSpace(line)
+	if !strings.HasPrefix(line, ".include") {
+		return ""
+	}
+
+	rest := strings.TrimSpace(line[len(".include"):])
+	if len(rest) < 2 {
+		return ""
+	}
+
+	open, close := byte('"'), byte('"')
+	if rest[0] == '<' {
+		open, close = '<', '>'
+	}
+	if rest[0] != open {
+		return ""
+	}
+
+	end := strings.IndexByte(rest[1:], close)
+	if end < 0 {
+		return ""
+	}
+
+	return rest[1 : end+1]
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeAsmFixture writes contents to name under dir and returns the full
+// path, failing the test on any write error.
+func writeAsmFixture(t *testing.T, dir, name, contents string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(contents), 0644); err != nil {
+		t.Fatalf("failed to write fixture %s: %v", name, err)
+	}
+	return path
+}
+
+// TestCacheKeyChangesWithAssembler locks in the chunk0-4 fix: switching
+// --assembler=native/external on an otherwise-unchanged file must produce a
+// different cache key, or a rebuild after the switch would silently reuse
+// the other backend's cached machine code.
+func TestCacheKeyChangesWithAssembler(t *testing.T) {
+	dir := t.TempDir()
+	file := writeAsmFixture(t, dir, "main.asm", "nop")
+	linkerSymbols = map[string]uint32{}
+
+	assembler = "native"
+	nativeKey, ok := cacheKey(file, 0x80001000)
+	if !ok {
+		t.Fatal("cacheKey failed on a file that exists")
+	}
+
+	assembler = "external"
+	externalKey, ok := cacheKey(file, 0x80001000)
+	if !ok {
+		t.Fatal("cacheKey failed on a file that exists")
+	}
+
+	if nativeKey == externalKey {
+		t.Error("cacheKey should differ between assembler backends, got the same key for both")
+	}
+}
+
+// TestCacheKeyChangesWithMTime guards the main premise of the compile cache:
+// touching a source file must invalidate its cache entry.
+func TestCacheKeyChangesWithMTime(t *testing.T) {
+	dir := t.TempDir()
+	file := writeAsmFixture(t, dir, "main.asm", "nop")
+	assembler = "native"
+	linkerSymbols = map[string]uint32{}
+
+	before, ok := cacheKey(file, 0x80001000)
+	if !ok {
+		t.Fatal("cacheKey failed on a file that exists")
+	}
+
+	later := time.Now().Add(time.Hour)
+	if err := os.Chtimes(file, later, later); err != nil {
+		t.Fatalf("failed to bump mtime: %v", err)
+	}
+
+	after, ok := cacheKey(file, 0x80001000)
+	if !ok {
+		t.Fatal("cacheKey failed on a file that exists")
+	}
+
+	if before == after {
+		t.Error("cacheKey should change when the file's mtime changes")
+	}
+}
+
+// TestCacheKeyChangesWithLinkerSymbols guards the other half of cacheKey's
+// contract: a relinked symbol address must invalidate cache entries for
+// every file that might reference it, since cacheKey has no way to know
+// which files actually use a given symbol.
+func TestCacheKeyChangesWithLinkerSymbols(t *testing.T) {
+	dir := t.TempDir()
+	file := writeAsmFixture(t, dir, "main.asm", "nop")
+	assembler = "native"
+
+	linkerSymbols = map[string]uint32{"my_func": 0x80001000}
+	before, ok := cacheKey(file, 0x80001000)
+	if !ok {
+		t.Fatal("cacheKey failed on a file that exists")
+	}
+
+	linkerSymbols = map[string]uint32{"my_func": 0x80002000}
+	after, ok := cacheKey(file, 0x80001000)
+	if !ok {
+		t.Fatal("cacheKey failed on a file that exists")
+	}
+
+	if before == after {
+		t.Error("cacheKey should change when a linker symbol's address changes")
+	}
+}
+
+// TestCacheKeyStableAcrossCalls checks the inverse of the above: with
+// nothing changed, the same file must hash to the same key every time, or
+// the cache would never hit.
+func TestCacheKeyStableAcrossCalls(t *testing.T) {
+	dir := t.TempDir()
+	file := writeAsmFixture(t, dir, "main.asm", "nop")
+	assembler = "native"
+	linkerSymbols = map[string]uint32{}
+
+	first, ok := cacheKey(file, 0x80001000)
+	if !ok {
+		t.Fatal("cacheKey failed on a file that exists")
+	}
+	second, ok := cacheKey(file, 0x80001000)
+	if !ok {
+		t.Fatal("cacheKey failed on a file that exists")
+	}
+
+	if first != second {
+		t.Error("cacheKey should be stable across calls when nothing changed")
+	}
+}
+
+// TestCacheKeyIncludesIncludedFileContents checks that changing a file
+// pulled in via .include also changes the including file's cache key, since
+// the including file's compiled output depends on it.
+func TestCacheKeyIncludesIncludedFileContents(t *testing.T) {
+	dir := t.TempDir()
+	writeAsmFixture(t, dir, "consts.inc", ".set FOO, 1\n")
+	file := writeAsmFixture(t, dir, "main.asm", ".include \"consts.inc\"\n.long FOO")
+	assembler = "native"
+	linkerSymbols = map[string]uint32{}
+
+	before, ok := cacheKey(file, 0x80001000)
+	if !ok {
+		t.Fatal("cacheKey failed on a file that exists")
+	}
+
+	writeAsmFixture(t, dir, "consts.inc", ".set FOO, 2\n")
+	after, ok := cacheKey(file, 0x80001000)
+	if !ok {
+		t.Fatal("cacheKey failed on a file that exists")
+	}
+
+	if before == after {
+		t.Error("cacheKey should change when an .included file's contents change")
+	}
+}
+
+// TestCacheKeyMissingFileFails checks cacheKey's ok=false path, used by
+// loadCompileCache/storeCompileCache to skip the cache entirely for a file
+// that can't be stat'd.
+func TestCacheKeyMissingFileFails(t *testing.T) {
+	if _, ok := cacheKey(filepath.Join(t.TempDir(), "does_not_exist.asm"), 0x80001000); ok {
+		t.Fatal("expected cacheKey to fail on a missing file")
+	}
+}
+
+// TestCompileCacheRoundTrip exercises storeCompileCache/loadCompileCache
+// together: bytes stored under a file's current cacheKey must be the bytes
+// loadCompileCache returns for that same, unchanged file.
+func TestCompileCacheRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	file := writeAsmFixture(t, dir, "main.asm", "nop")
+	assembler = "native"
+	linkerSymbols = map[string]uint32{}
+
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("failed to get working directory: %v", err)
+	}
+	defer os.Chdir(wd)
+	if err := os.Chdir(dir); err != nil {
+		t.Fatalf("failed to chdir into fixture dir: %v", err)
+	}
+
+	if _, ok := loadCompileCache(file, 0x80001000); ok {
+		t.Fatal("loadCompileCache should miss before anything is stored")
+	}
+
+	want := []byte{0xDE, 0xAD, 0xBE, 0xEF}
+	storeCompileCache(file, 0x80001000, want)
+
+	got, ok := loadCompileCache(file, 0x80001000)
+	if !ok {
+		t.Fatal("loadCompileCache should hit right after storeCompileCache")
+	}
+	if string(got) != string(want) {
+		t.Errorf("loadCompileCache = %v, want %v", got, want)
+	}
+}
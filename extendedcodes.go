@@ -0,0 +1,304 @@
+package main
+
+import (
+	"encoding/hex"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+)
+
+// codeScope tracks the state a CodeDescription's Build list carries across
+// entries: how many If* conditionals are still open (so EndIf/Terminate can
+// be validated), and whether a base address (ba) or pointer offset (po) is
+// currently active (so codes flagged UseBaseAddress/UsePointer can be
+// checked against it).
+type codeScope struct {
+	ifDepth           int
+	baseAddressActive bool
+	pointerActive     bool
+}
+
+// finish is called once a CodeDescription's whole Build list has been
+// processed, and catches an if block that was never closed with an EndIf.
+func (s *codeScope) finish() {
+	if s.ifDepth != 0 {
+		log.Panicf("Code has %d unclosed if-block(s); every if must be matched by an endIf\n", s.ifDepth)
+	}
+}
+
+// checkAddressFlags validates that a code using UseBaseAddress/UsePointer
+// only does so once the corresponding setBaseAddress/setPointerOffset code
+// has run earlier in the same Build list.
+func (s *codeScope) checkAddressFlags(geckoCode GeckoCode) {
+	if geckoCode.UseBaseAddress && !s.baseAddressActive {
+		log.Panicf(
+			"Code at %s sets UseBaseAddress but no setBaseAddress code precedes it\n",
+			geckoCode.Address,
+		)
+	}
+	if geckoCode.UsePointer && !s.pointerActive {
+		log.Panicf(
+			"Code at %s sets UsePointer but no setPointerOffset code precedes it\n",
+			geckoCode.Address,
+		)
+	}
+}
+
+// generateExtendedCodeLines emits the rest of the documented Gecko
+// codetypes beyond replace/inject/replaceCodeBlock/branch: byte/half
+// writes, serial repeats, conditional if/endif blocks, base
+// address/pointer offset codes, Gecko register ops, execute-with-return,
+// and full termination.
+func generateExtendedCodeLines(geckoCode GeckoCode, instructions []byte, scope *codeScope) []string {
+	switch geckoCode.Type {
+	case WriteByte:
+		scope.checkAddressFlags(geckoCode)
+		return []string{sizedWriteLine("00", geckoCode)}
+	case WriteHalf:
+		scope.checkAddressFlags(geckoCode)
+		return []string{sizedWriteLine("02", geckoCode)}
+	case SerialWrite:
+		scope.checkAddressFlags(geckoCode)
+		return serialWriteLines(geckoCode)
+
+	case IfEqual32, IfNotEqual32, IfGreaterThan32,
+		IfEqual16, IfNotEqual16, IfGreaterThan16,
+		IfEqual8, IfNotEqual8, IfGreaterThan8:
+		scope.checkAddressFlags(geckoCode)
+		scope.ifDepth++
+		return []string{conditionalLine(geckoCode)}
+	case EndIf:
+		closeCount := geckoCode.Count
+		if closeCount == 0 {
+			closeCount = 1
+		}
+		if uint32(scope.ifDepth) < closeCount {
+			log.Panicf(
+				"endIf closes %d if-block(s) but only %d are open\n",
+				closeCount, scope.ifDepth,
+			)
+		}
+		scope.ifDepth -= int(closeCount)
+		return []string{fmt.Sprintf("E0000000 %08X", closeCount)}
+
+	case SetBaseAddress:
+		scope.baseAddressActive = true
+		return []string{fmt.Sprintf("40000000 %s", hexWord(geckoCode.Value))}
+	case SetPointerOffset:
+		scope.pointerActive = true
+		return []string{fmt.Sprintf("48000000 %s", hexWord(geckoCode.Value))}
+	case StoreBaseAddress:
+		scope.baseAddressActive = false
+		return []string{fmt.Sprintf("50000000 %s", hexWord(geckoCode.Value))}
+	case StorePointerOffset:
+		scope.pointerActive = false
+		return []string{fmt.Sprintf("58000000 %s", hexWord(geckoCode.Value))}
+
+	case GeckoRegisterSet:
+		return []string{fmt.Sprintf("80%06X %s", geckoCode.Register, hexWord(geckoCode.Value))}
+	case GeckoRegisterLoad:
+		scope.checkAddressFlags(geckoCode)
+		return []string{fmt.Sprintf("82%06X %s", geckoCode.Register, addressField(geckoCode))}
+	case GeckoRegisterStore:
+		scope.checkAddressFlags(geckoCode)
+		return []string{fmt.Sprintf("84%06X %s", geckoCode.Register, addressField(geckoCode))}
+	case GeckoRegisterArithmetic:
+		return []string{geckoRegisterArithmeticLine(geckoCode)}
+
+	case ExecuteAsm:
+		scope.checkAddressFlags(geckoCode)
+		return executeAsmLines(geckoCode, instructions)
+	case Terminate:
+		return []string{"F0000000 00000000"}
+	}
+
+	log.Panicf("Unknown gecko code type %q\n", geckoCode.Type)
+	return nil
+}
+
+// executeAsmLines builds a C0 "execute ASM, then return to the original
+// code" block: unlike Inject (C2), which patches the overwritten original
+// instruction back in before returning, C0 just runs the block and returns,
+// so it never collapses down to a plain 04 write the way Inject does.
+func executeAsmLines(geckoCode GeckoCode, instructions []byte) []string {
+	if len(instructions) == 0 {
+		log.Panicf("Did not find any code in file: %s\n", geckoCode.SourceFile)
+	}
+
+	if len(instructions)%8 != 0 {
+		instructions = append(instructions, 0x00, 0x00, 0x00, 0x00)
+	}
+
+	lines := []string{fmt.Sprintf("C0%s %08X", addressField(geckoCode), len(instructions)/8)}
+
+	for i := 0; i < len(instructions); i += 8 {
+		left := strings.ToUpper(hex.EncodeToString(instructions[i : i+4]))
+		right := strings.ToUpper(hex.EncodeToString(instructions[i+4 : i+8]))
+		lines = append(lines, fmt.Sprintf("%s %s", left, right))
+	}
+
+	return lines
+}
+
+// sizedWriteLine builds the single-line "TTAAAAAA YYYY00VV"/"TTAAAAAA YYYYVVVV"
+// write codes (writeByte/writeHalf), optionally repeated via Count. The
+// value is masked to the declared width so it can never bleed into the
+// repeat-count field.
+func sizedWriteLine(typeByte string, geckoCode GeckoCode) string {
+	repeat := geckoCode.Count
+
+	switch typeByte {
+	case "00":
+		return fmt.Sprintf(
+			"%s%s %04X00%02X",
+			typeByte, addressField(geckoCode), repeat, parseHexN(geckoCode.Value, 8),
+		)
+	case "02":
+		return fmt.Sprintf(
+			"%s%s %04X%04X",
+			typeByte, addressField(geckoCode), repeat, parseHexN(geckoCode.Value, 16),
+		)
+	}
+
+	log.Panicf("sizedWriteLine: unknown type byte %q\n", typeByte)
+	return ""
+}
+
+// serialWriteLines builds the repeat-write (42) codetype: a base write plus
+// the repeat count and per-iteration address/value steps.
+func serialWriteLines(geckoCode GeckoCode) []string {
+	return []string{
+		fmt.Sprintf("42%s %s", addressField(geckoCode), hexWord(geckoCode.Value)),
+		fmt.Sprintf("%04X%04X %s", geckoCode.Count, parseHex16(geckoCode.AddressStep), hexWord(geckoCode.ValueStep)),
+	}
+}
+
+// conditionalLine builds a 2X/28/38 if-equal/if-not-equal/if-greater-than
+// code, masking the compare word for the 16/8 bit variants.
+func conditionalLine(geckoCode GeckoCode) string {
+	typeByte, size := conditionalTypeByte(geckoCode.Type)
+
+	var compareWord string
+	switch size {
+	case 32:
+		compareWord = hexWord(geckoCode.Value)
+	case 16, 8:
+		mask := geckoCode.Mask
+		if mask == "" {
+			mask = "FFFF"
+		}
+		compareWord = fmt.Sprintf("%04X%04X", parseHex16(mask), parseHex16(geckoCode.Value))
+	}
+
+	return fmt.Sprintf("%s%s %s", typeByte, addressField(geckoCode), compareWord)
+}
+
+func conditionalTypeByte(codeType string) (string, int) {
+	switch codeType {
+	case IfEqual32:
+		return "20", 32
+	case IfNotEqual32:
+		return "22", 32
+	case IfGreaterThan32:
+		return "24", 32
+	case IfEqual16:
+		return "28", 16
+	case IfNotEqual16:
+		return "2A", 16
+	case IfGreaterThan16:
+		return "2C", 16
+	case IfEqual8:
+		return "38", 8
+	case IfNotEqual8:
+		return "3A", 8
+	case IfGreaterThan8:
+		return "3C", 8
+	}
+
+	log.Panicf("Unknown conditional code type %q\n", codeType)
+	return "", 0
+}
+
+// geckoRegisterOps maps the Operation field of a geckoRegisterArithmetic
+// code to the XO sub-opcode the Gecko codehandler expects.
+var geckoRegisterOps = map[string]uint32{
+	"add": 0, "mul": 1, "or": 2, "and": 3,
+	"xor": 4, "shl": 5, "shr": 6, "sub": 7, "mov": 9, "not": 10,
+}
+
+func geckoRegisterArithmeticLine(geckoCode GeckoCode) string {
+	op, ok := geckoRegisterOps[geckoCode.Operation]
+	if !ok {
+		log.Panicf("Unknown gecko register operation %q\n", geckoCode.Operation)
+	}
+
+	return fmt.Sprintf("86%06X %02X%06X", geckoCode.Register, op, parseHex24(geckoCode.Value))
+}
+
+// addressField renders a code's Address as the 6 hex digit offset the
+// extended codetypes use, with the top nibble flagging whether it's
+// relative to the active base address (ba) and/or pointer offset (po).
+func addressField(geckoCode GeckoCode) string {
+	addrUint, err := strconv.ParseUint(strings.TrimPrefix(geckoCode.Address, "0x"), 16, 32)
+	if err != nil {
+		log.Panicf("Failed to parse address %q\n%s\n", geckoCode.Address, err.Error())
+	}
+
+	var flags uint32
+	if geckoCode.UsePointer {
+		flags |= 0x1
+	}
+	if geckoCode.UseBaseAddress {
+		flags |= 0x2
+	}
+
+	field := flags<<20 | (uint32(addrUint) & 0xFFFFF)
+	return fmt.Sprintf("%06X", field)
+}
+
+// hexWord normalizes value to an 8 hex digit, uppercase word.
+func hexWord(value string) string {
+	v, err := strconv.ParseUint(strings.TrimPrefix(value, "0x"), 16, 32)
+	if err != nil {
+		log.Panicf("Failed to parse value %q\n%s\n", value, err.Error())
+	}
+	return fmt.Sprintf("%08X", v)
+}
+
+// parseHexN parses value as an unsigned integer that must fit in bits bits,
+// rejecting (rather than truncating) anything wider.
+func parseHexN(value string, bits int) uint32 {
+	if value == "" {
+		return 0
+	}
+	v, err := strconv.ParseUint(strings.TrimPrefix(value, "0x"), 16, bits)
+	if err != nil {
+		log.Panicf("Failed to parse %d-bit value %q\n%s\n", bits, value, err.Error())
+	}
+	return uint32(v)
+}
+
+// parseHex16 normalizes value to a 16-bit unsigned integer.
+func parseHex16(value string) uint32 {
+	if value == "" {
+		return 0
+	}
+	v, err := strconv.ParseUint(strings.TrimPrefix(value, "0x"), 16, 16)
+	if err != nil {
+		log.Panicf("Failed to parse 16-bit value %q\n%s\n", value, err.Error())
+	}
+	return uint32(v)
+}
+
+// parseHex24 normalizes value to a 24-bit unsigned integer.
+func parseHex24(value string) uint32 {
+	if value == "" {
+		return 0
+	}
+	v, err := strconv.ParseUint(strings.TrimPrefix(value, "0x"), 16, 32)
+	if err != nil {
+		log.Panicf("Failed to parse value %q\n%s\n", value, err.Error())
+	}
+	return uint32(v) & 0xFFFFFF
+}
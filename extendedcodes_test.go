@@ -0,0 +1,257 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestGenerateExtendedCodeLinesIfEndIf(t *testing.T) {
+	scope := &codeScope{}
+
+	ifLines := generateExtendedCodeLines(GeckoCode{
+		Type:    IfEqual32,
+		Address: "0x80001000",
+		Value:   "0x12345678",
+	}, nil, scope)
+	want := []string{"20001000 12345678"}
+	if !reflect.DeepEqual(ifLines, want) {
+		t.Errorf("if block: got %v, want %v", ifLines, want)
+	}
+	if scope.ifDepth != 1 {
+		t.Fatalf("if block: scope.ifDepth = %d, want 1", scope.ifDepth)
+	}
+
+	endLines := generateExtendedCodeLines(GeckoCode{Type: EndIf}, nil, scope)
+	want = []string{"E0000000 00000001"}
+	if !reflect.DeepEqual(endLines, want) {
+		t.Errorf("endIf: got %v, want %v", endLines, want)
+	}
+	if scope.ifDepth != 0 {
+		t.Fatalf("endIf: scope.ifDepth = %d, want 0", scope.ifDepth)
+	}
+
+	scope.finish()
+}
+
+func TestGenerateExtendedCodeLinesUnclosedIfPanics(t *testing.T) {
+	scope := &codeScope{}
+	generateExtendedCodeLines(GeckoCode{
+		Type:    IfEqual32,
+		Address: "0x80001000",
+		Value:   "0x0",
+	}, nil, scope)
+
+	defer func() {
+		if recover() == nil {
+			t.Fatal("expected scope.finish() to panic on an unclosed if-block")
+		}
+	}()
+	scope.finish()
+}
+
+func TestGenerateExtendedCodeLinesEndIfWithoutOpenIfPanics(t *testing.T) {
+	scope := &codeScope{}
+
+	defer func() {
+		if recover() == nil {
+			t.Fatal("expected endIf with no open if-block to panic")
+		}
+	}()
+	generateExtendedCodeLines(GeckoCode{Type: EndIf}, nil, scope)
+}
+
+func TestGenerateExtendedCodeLinesSerialWrite(t *testing.T) {
+	scope := &codeScope{}
+
+	lines := generateExtendedCodeLines(GeckoCode{
+		Type:        SerialWrite,
+		Address:     "0x80001000",
+		Value:       "0x00000005",
+		Count:       10,
+		AddressStep: "0x4",
+		ValueStep:   "0x1",
+	}, nil, scope)
+
+	want := []string{
+		"42001000 00000005",
+		"000A0004 00000001",
+	}
+	if !reflect.DeepEqual(lines, want) {
+		t.Errorf("got %v, want %v", lines, want)
+	}
+}
+
+func TestCheckAddressFlagsRequiresBaseAddress(t *testing.T) {
+	scope := &codeScope{}
+
+	defer func() {
+		if recover() == nil {
+			t.Fatal("expected UseBaseAddress without a preceding setBaseAddress to panic")
+		}
+	}()
+	generateExtendedCodeLines(GeckoCode{
+		Type:           WriteByte,
+		Address:        "0x1000",
+		Value:          "0x1",
+		UseBaseAddress: true,
+	}, nil, scope)
+}
+
+func TestCheckAddressFlagsRequiresPointer(t *testing.T) {
+	scope := &codeScope{}
+
+	defer func() {
+		if recover() == nil {
+			t.Fatal("expected UsePointer without a preceding setPointerOffset to panic")
+		}
+	}()
+	generateExtendedCodeLines(GeckoCode{
+		Type:       WriteByte,
+		Address:    "0x1000",
+		Value:      "0x1",
+		UsePointer: true,
+	}, nil, scope)
+}
+
+func TestGenerateExtendedCodeLinesSizedWrites(t *testing.T) {
+	scope := &codeScope{}
+
+	byteLines := generateExtendedCodeLines(GeckoCode{
+		Type:    WriteByte,
+		Address: "0x80001000",
+		Value:   "0x34",
+		Count:   2,
+	}, nil, scope)
+	if want := []string{"00001000 00020034"}; !reflect.DeepEqual(byteLines, want) {
+		t.Errorf("writeByte: got %v, want %v", byteLines, want)
+	}
+
+	halfLines := generateExtendedCodeLines(GeckoCode{
+		Type:    WriteHalf,
+		Address: "0x80001000",
+		Value:   "0x1234",
+		Count:   2,
+	}, nil, scope)
+	if want := []string{"02001000 00021234"}; !reflect.DeepEqual(halfLines, want) {
+		t.Errorf("writeHalf: got %v, want %v", halfLines, want)
+	}
+}
+
+func TestGenerateExtendedCodeLinesWriteByteRejectsOversizedValue(t *testing.T) {
+	scope := &codeScope{}
+
+	defer func() {
+		if recover() == nil {
+			t.Fatal("expected writeByte with a Value > 0xFF to panic instead of bleeding into the repeat field")
+		}
+	}()
+	generateExtendedCodeLines(GeckoCode{
+		Type:    WriteByte,
+		Address: "0x80001000",
+		Value:   "0x1234",
+	}, nil, scope)
+}
+
+func TestGenerateExtendedCodeLinesGeckoRegisterSetLoadStore(t *testing.T) {
+	scope := &codeScope{}
+
+	setLines := generateExtendedCodeLines(GeckoCode{
+		Type:     GeckoRegisterSet,
+		Register: 3,
+		Value:    "0x80001000",
+	}, nil, scope)
+	if want := []string{"80000003 80001000"}; !reflect.DeepEqual(setLines, want) {
+		t.Errorf("geckoRegisterSet: got %v, want %v", setLines, want)
+	}
+
+	loadLines := generateExtendedCodeLines(GeckoCode{
+		Type:     GeckoRegisterLoad,
+		Register: 3,
+		Address:  "0x80001000",
+	}, nil, scope)
+	if want := []string{"82000003 001000"}; !reflect.DeepEqual(loadLines, want) {
+		t.Errorf("geckoRegisterLoad: got %v, want %v", loadLines, want)
+	}
+
+	storeLines := generateExtendedCodeLines(GeckoCode{
+		Type:     GeckoRegisterStore,
+		Register: 3,
+		Address:  "0x80001000",
+	}, nil, scope)
+	if want := []string{"84000003 001000"}; !reflect.DeepEqual(storeLines, want) {
+		t.Errorf("geckoRegisterStore: got %v, want %v", storeLines, want)
+	}
+}
+
+func TestGenerateExtendedCodeLinesGeckoRegisterArithmetic(t *testing.T) {
+	scope := &codeScope{}
+
+	for op, code := range map[string]string{
+		"add": "00", "mul": "01", "or": "02", "and": "03",
+		"xor": "04", "shl": "05", "shr": "06", "sub": "07",
+		"mov": "09", "not": "0A",
+	} {
+		lines := generateExtendedCodeLines(GeckoCode{
+			Type:      GeckoRegisterArithmetic,
+			Register:  1,
+			Operation: op,
+			Value:     "0x5",
+		}, nil, scope)
+		want := []string{"86000001 " + code + "000005"}
+		if !reflect.DeepEqual(lines, want) {
+			t.Errorf("geckoRegisterArithmetic %q: got %v, want %v", op, lines, want)
+		}
+	}
+}
+
+func TestGenerateExtendedCodeLinesGeckoRegisterArithmeticUnknownOpPanics(t *testing.T) {
+	scope := &codeScope{}
+
+	defer func() {
+		if recover() == nil {
+			t.Fatal("expected an unknown Operation to panic")
+		}
+	}()
+	generateExtendedCodeLines(GeckoCode{
+		Type:      GeckoRegisterArithmetic,
+		Register:  1,
+		Operation: "nope",
+		Value:     "0x1",
+	}, nil, scope)
+}
+
+func TestCheckAddressFlagsAllowedAfterSetBaseAddress(t *testing.T) {
+	scope := &codeScope{}
+
+	setLines := generateExtendedCodeLines(GeckoCode{
+		Type:  SetBaseAddress,
+		Value: "0x80000000",
+	}, nil, scope)
+	if want := []string{"40000000 80000000"}; !reflect.DeepEqual(setLines, want) {
+		t.Errorf("setBaseAddress: got %v, want %v", setLines, want)
+	}
+	if !scope.baseAddressActive {
+		t.Fatal("setBaseAddress should mark scope.baseAddressActive")
+	}
+
+	writeLines := generateExtendedCodeLines(GeckoCode{
+		Type:           WriteByte,
+		Address:        "0x1000",
+		Value:          "0x0000007F",
+		UseBaseAddress: true,
+	}, nil, scope)
+	if want := []string{"00201000 0000007F"}; !reflect.DeepEqual(writeLines, want) {
+		t.Errorf("writeByte with UseBaseAddress: got %v, want %v", writeLines, want)
+	}
+
+	storeLines := generateExtendedCodeLines(GeckoCode{
+		Type:  StoreBaseAddress,
+		Value: "0x0",
+	}, nil, scope)
+	if want := []string{"50000000 00000000"}; !reflect.DeepEqual(storeLines, want) {
+		t.Errorf("storeBaseAddress: got %v, want %v", storeLines, want)
+	}
+	if scope.baseAddressActive {
+		t.Fatal("storeBaseAddress should clear scope.baseAddressActive")
+	}
+}
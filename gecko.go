@@ -5,6 +5,7 @@ import (
 	"bytes"
 	"encoding/hex"
 	"encoding/json"
+	"flag"
 	"fmt"
 	"io/ioutil"
 	"log"
@@ -14,11 +15,33 @@ import (
 	"runtime"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
+
+	"github.com/hosaka-corp/gecko/internal/ppcas"
 )
 
 type Config struct {
 	OutputFiles []string
 	Codes       []CodeDescription
+
+	// Symbols maps names to game addresses (e.g. "Gecko_Process":
+	// "0x8016B910") so Build sources can reference them by name (bl
+	// Gecko_Process, lis r3, Gecko_Process@ha) instead of hardcoding hex.
+	// Merged with any auto-discovered symbols.txt, and with the symbols
+	// exported by Library, into the project's shared linker symbol table.
+	Symbols map[string]string
+	// Library places every .asm file in a folder into one shared region at
+	// a fixed address, so their .global labels can be linked against from
+	// any other Build source in the project.
+	Library *LibraryConfig
+
+	// TextFormat selects the variant written for an OutputFiles entry whose
+	// extension isn't .gct/.ini/.xml: "" (the default) writes plain
+	// $Name/*description/hex-line text, and "ocarina" wraps the same body
+	// in the "[Gecko Codes]" banner Ocarina and GCT-RAM-Manager (GCTRM)
+	// expect at the top of an importable .txt code dump.
+	TextFormat string
 }
 
 type CodeDescription struct {
@@ -37,6 +60,29 @@ type GeckoCode struct {
 	SourceFile    string
 	SourceFolder  string
 	Value         string
+
+	// Mask is an optional compare mask for the If* conditional codetypes,
+	// applied to the upper half of the compare word for the 16/8 bit
+	// variants. Defaults to "FFFF" (no masking) when empty.
+	Mask string
+	// Count is the repeat count for SerialWrite, or the number of nested
+	// if-scopes EndIf closes (defaults to 1 when zero).
+	Count uint32
+	// AddressStep and ValueStep are the per-repeat address/value
+	// increments used by SerialWrite.
+	AddressStep string
+	ValueStep   string
+	// UseBaseAddress/UsePointer mark Address as relative to the base
+	// address (ba) or pointer offset (po) set by a prior
+	// SetBaseAddress/SetPointerOffset code.
+	UseBaseAddress bool
+	UsePointer     bool
+	// Register and Operation are used by the Gecko register codetypes:
+	// Register selects the gecko register (0-15), Operation selects the
+	// arithmetic/logical op for GeckoRegisterArithmetic ("add", "sub",
+	// "mul", "or", "and", "xor", "not", "mov", "shl", "shr").
+	Register  uint32
+	Operation string
 }
 
 const (
@@ -47,9 +93,85 @@ const (
 	BranchAndLink    = "branchAndLink"
 	InjectFolder     = "injectFolder"
 	ReplaceBinary    = "replaceBinary"
+
+	// Extended codetype set (Gecko codehandler 00/02/20-3E/40-58/80-86/C0/F0).
+	WriteByte   = "writeByte"
+	WriteHalf   = "writeHalf"
+	SerialWrite = "serialWrite"
+	ExecuteAsm  = "executeAsm"
+	Terminate   = "terminate"
+
+	IfEqual32       = "ifEqual32"
+	IfNotEqual32    = "ifNotEqual32"
+	IfGreaterThan32 = "ifGreaterThan32"
+	IfEqual16       = "ifEqual16"
+	IfNotEqual16    = "ifNotEqual16"
+	IfGreaterThan16 = "ifGreaterThan16"
+	IfEqual8        = "ifEqual8"
+	IfNotEqual8     = "ifNotEqual8"
+	IfGreaterThan8  = "ifGreaterThan8"
+	EndIf           = "endIf"
+
+	SetBaseAddress     = "setBaseAddress"
+	SetPointerOffset   = "setPointerOffset"
+	StoreBaseAddress   = "storeBaseAddress"
+	StorePointerOffset = "storePointerOffset"
+
+	GeckoRegisterSet        = "geckoRegisterSet"
+	GeckoRegisterLoad       = "geckoRegisterLoad"
+	GeckoRegisterStore      = "geckoRegisterStore"
+	GeckoRegisterArithmetic = "geckoRegisterArithmetic"
 )
 
-var output []string
+// OutputHeader is a code's $Name [Authors] line plus its *description
+// lines, kept structured (rather than pre-rendered into text) so
+// format-specific writers can place Name/Authors/Description into their
+// own fields instead of re-parsing a "$Name [Authors]" string.
+type OutputHeader struct {
+	Name        string
+	Authors     []string
+	Description []string
+}
+
+// OutputEntry is one self-contained unit of build output: either a
+// codes.json CodeDescription or the auto-linked library region. Every
+// writeXOutput function reads from these instead of the old flat
+// []string, so each writer gets real structure to work from instead of
+// having to string-match a specific shape out of a rendered line (the way
+// writeGctOutput used to guess which lines were code by checking length).
+type OutputEntry struct {
+	// Kind distinguishes a regular code ("code") from the auto-linked
+	// library region ("library"), for writers that want to treat them
+	// differently (e.g. Dolphin's ini format still lists both the same
+	// way, so today nothing branches on it).
+	Kind string
+
+	Header OutputHeader
+
+	// Codes holds each code's hex line exactly as "TTAAAAAA VVVVVVVV",
+	// with no trailing comment, so every writer can hex.DecodeString them
+	// directly.
+	Codes []string
+	// Annotations is parallel to Codes: the optional inline comment for
+	// that code line (e.g. an InjectFolder file's path, or a GeckoCode's
+	// Annotation field), or "" if the line has none.
+	Annotations []string
+}
+
+var output []OutputEntry
+
+// assembler selects which backend compile() uses to turn .asm files into
+// machine code: "native" (the built-in internal/ppcas assembler, no
+// external dependency) or "external" (shell out to powerpc-eabi-as, kept
+// around so projects can fall back to the toolchain during migration).
+var assembler string
+
+// verbose streams per-file compile timing to stderr when set via -v.
+var verbose bool
+
+// workerSem bounds how many .asm files are compiled concurrently, sized by
+// -n (defaults to runtime.NumCPU()).
+var workerSem chan struct{}
 
 func main() {
 	defer func() {
@@ -61,19 +183,56 @@ func main() {
 		log.Panic("Must provide a command. Try typing 'gecko build'\n")
 	}
 
-	if os.Args[1] != "build" {
-		log.Panic("Currently only the build command is supported. Try typing 'gecko build'\n")
+	command := os.Args[1]
+	if command != "build" && command != "watch" && command != "test" {
+		log.Panic("Currently only the build, watch, and test commands are supported. Try typing 'gecko build'\n")
+	}
+
+	flags := flag.NewFlagSet(command, flag.ExitOnError)
+	flags.StringVar(&assembler, "assembler", "native", "assembler backend to use: native or external")
+	parallelism := flags.Int("n", runtime.NumCPU(), "number of .asm files to compile concurrently")
+	flags.BoolVar(&verbose, "v", false, "stream per-file compile timing to stderr")
+	testDir := flags.String("dir", ".", "directory to search for *"+testFileSuffix+" files (test command only)")
+	flags.Parse(os.Args[2:])
+
+	if assembler != "native" && assembler != "external" {
+		log.Panicf("Unknown assembler backend %q. Must be 'native' or 'external'\n", assembler)
+	}
+
+	if *parallelism < 1 {
+		*parallelism = 1
 	}
+	workerSem = make(chan struct{}, *parallelism)
 
-	config := readConfigFile()
+	switch command {
+	case "watch":
+		runWatch()
+	case "test":
+		if !runGeckoTests(*testDir) {
+			os.Exit(1)
+		}
+	default:
+		runBuild(readConfigFile())
+	}
+}
+
+// runBuild generates and writes every output file for config. It's the
+// entire body of `gecko build`, and is also what `gecko watch` re-runs
+// every time a watched dependency changes.
+func runBuild(config Config) {
 	if len(config.OutputFiles) < 1 {
 		log.Panic("Must have at least one output file configured in the outputFiles field\n")
 	}
 
+	output = nil
 	buildBody(config)
 
 	for _, file := range config.OutputFiles {
-		writeOutput(file)
+		writeOutput(file, config.TextFormat)
+	}
+
+	if len(config.Symbols) > 0 || config.Library != nil {
+		writeSymbolsMap("symbols.map")
 	}
 }
 
@@ -96,25 +255,52 @@ func readConfigFile() Config {
 }
 
 func buildBody(config Config) {
-	// go through every code and print a header and the codes that make it up
+	// Resolve symbols.txt/config.Symbols/Library up front so every Build
+	// source compiled below can reference them by name.
+	libraryLines := resolveLinkerSymbols(config)
+
+	// go through every code and build a header and the codes that make it up
 	for _, code := range config.Codes {
-		headerLines := generateHeaderLines(code)
-		output = append(output, headerLines...)
+		header := OutputHeader{Name: code.Name, Authors: code.Authors, Description: code.Description}
+		output = append(output, buildOutputEntry("code", header, generateCodeLines(code)))
+	}
 
-		codeLines := generateCodeLines(code)
-		// TODO: Add description
-		output = append(output, codeLines...)
-		output = append(output, "")
+	if libraryLines != nil {
+		header := OutputHeader{Name: "Library", Authors: []string{"auto-linked"}}
+		output = append(output, buildOutputEntry("library", header, libraryLines))
 	}
 }
 
-func generateHeaderLines(desc CodeDescription) []string {
-	result := []string{}
+// buildOutputEntry splits rawCodeLines (as produced by generate*Lines,
+// still carrying any inline "#annotation" suffix addLineAnnotation added)
+// into an OutputEntry's parallel Codes/Annotations fields.
+func buildOutputEntry(kind string, header OutputHeader, rawCodeLines []string) OutputEntry {
+	entry := OutputEntry{Kind: kind, Header: header}
+
+	for _, line := range rawCodeLines {
+		code, annotation := splitCodeLine(line)
+		entry.Codes = append(entry.Codes, code)
+		entry.Annotations = append(entry.Annotations, annotation)
+	}
+
+	return entry
+}
+
+// splitCodeLine separates a generate*Lines line from the optional inline
+// " #annotation" addLineAnnotation appended to it.
+func splitCodeLine(line string) (string, string) {
+	if idx := strings.Index(line, " #"); idx >= 0 {
+		return line[:idx], line[idx+2:]
+	}
+	return line, ""
+}
 
-	authorString := strings.Join(desc.Authors, ", ")
-	result = append(result, fmt.Sprintf("$%s [%s]", desc.Name, authorString))
+// renderHeaderLines reconstructs the "$Name [Authors]"/"*description"
+// text lines a text-based writer needs from a structured OutputHeader.
+func renderHeaderLines(header OutputHeader) []string {
+	result := []string{fmt.Sprintf("$%s [%s]", header.Name, strings.Join(header.Authors, ", "))}
 
-	for _, line := range desc.Description {
+	for _, line := range header.Description {
 		result = append(result, fmt.Sprintf("*%s", line))
 	}
 
@@ -124,18 +310,26 @@ func generateHeaderLines(desc CodeDescription) []string {
 func generateCodeLines(desc CodeDescription) []string {
 	result := []string{}
 
-	for _, geckoCode := range desc.Build {
+	// Compile every Inject/ReplaceCodeBlock source up front so the asm
+	// files in this code's Build list are assembled concurrently instead
+	// of one at a time; the switch below then just consumes the bytes
+	// already sitting in the matching slot.
+	instructions := precompileBuildSources(desc.Build)
+
+	scope := &codeScope{}
+
+	for i, geckoCode := range desc.Build {
 		switch geckoCode.Type {
 		case Replace:
 			line := generateReplaceCodeLine(geckoCode.Address, geckoCode.Value)
 			line = addLineAnnotation(line, geckoCode.Annotation)
 			result = append(result, line)
 		case Inject:
-			lines := generateInjectionCodeLines(geckoCode.Address, geckoCode.SourceFile)
+			lines := generateInjectionCodeLines(geckoCode.Address, instructions[i], geckoCode.SourceFile)
 			lines[0] = addLineAnnotation(lines[0], geckoCode.Annotation)
 			result = append(result, lines...)
 		case ReplaceCodeBlock:
-			lines := generateReplaceCodeBlockLines(geckoCode.Address, geckoCode.SourceFile)
+			lines := generateReplaceCodeBlockLines(geckoCode.Address, instructions[i], geckoCode.SourceFile)
 			lines[0] = addLineAnnotation(lines[0], geckoCode.Annotation)
 			result = append(result, lines...)
 		case ReplaceBinary:
@@ -152,15 +346,97 @@ func generateCodeLines(desc CodeDescription) []string {
 		case InjectFolder:
 			lines := generateInjectionFolderLines(geckoCode.SourceFolder, geckoCode.IsRecursive)
 			result = append(result, lines...)
+		default:
+			lines := generateExtendedCodeLines(geckoCode, instructions[i], scope)
+			lines[0] = addLineAnnotation(lines[0], geckoCode.Annotation)
+			result = append(result, lines...)
 		}
 	}
 
+	scope.finish()
+
 	return result
 }
 
+// precompileBuildSources assembles every Inject/ReplaceCodeBlock source
+// file referenced by build concurrently, returning the compiled bytes in
+// a slice aligned to build's indices (other entries are left nil).
+func precompileBuildSources(build []GeckoCode) [][]byte {
+	instructions := make([][]byte, len(build))
+
+	parallelFor(len(build), func(i int) {
+		geckoCode := build[i]
+		if geckoCode.Type != Inject && geckoCode.Type != ReplaceCodeBlock && geckoCode.Type != ExecuteAsm {
+			return
+		}
+
+		instructions[i] = timedCompile(geckoCode.SourceFile, addressToUint32(geckoCode.Address))
+	})
+
+	return instructions
+}
+
+// parallelFor runs fn(i) for i in [0, items) across a bounded pool of
+// goroutines sized by workerSem, collecting each job's result into the
+// caller's own per-index slot so output order stays deterministic
+// regardless of completion order.
+//
+// compile()/compileNative()/compileExternal() report failures via
+// log.Panicf rather than returning an error, and a panic in a non-main
+// goroutine crashes the whole process with a raw Go stack trace instead
+// of going through main()'s top-level recover. Each worker recovers its
+// own panic and parallelFor re-panics the first one on the calling
+// goroutine once every worker has finished, so a compile failure is
+// reported the same way (a clean one-line error, no stack trace) no
+// matter which worker hit it.
+func parallelFor(items int, fn func(i int)) {
+	var wg sync.WaitGroup
+	var panicOnce sync.Once
+	var panicValue interface{}
+	for i := 0; i < items; i++ {
+		wg.Add(1)
+		workerSem <- struct{}{}
+		go func(i int) {
+			defer wg.Done()
+			defer func() { <-workerSem }()
+			defer func() {
+				if r := recover(); r != nil {
+					panicOnce.Do(func() { panicValue = r })
+				}
+			}()
+			fn(i)
+		}(i)
+	}
+	wg.Wait()
+
+	if panicValue != nil {
+		panic(panicValue)
+	}
+}
+
+// timedCompile wraps compile() with the -v verbose per-file timing output.
+func timedCompile(file string, baseAddress uint32) []byte {
+	start := time.Now()
+	instructions := compile(file, baseAddress)
+	if verbose {
+		fmt.Fprintf(os.Stderr, "compiled %s in %s\n", file, time.Since(start))
+	}
+	return instructions
+}
+
+// maskedAddress renders address as the 6 hex digit, upper-case field the
+// 04/06/C2 codetypes use (the codehandler always assumes the 0x8/0x0
+// region byte, so only the low 24 bits are encoded). Used instead of
+// slicing off just the "0x" prefix so the resulting line is exactly 17
+// characters (type byte + 6 hex digit address + space + 8 hex digit
+// value) and always hex.DecodeStrings cleanly in writeGctOutput.
+func maskedAddress(address string) string {
+	return fmt.Sprintf("%06X", addressToUint32(address)&0xFFFFFF)
+}
+
 func generateReplaceCodeLine(address, value string) string {
 	// TODO: Add error if address or value is incorrect length/format
-	return fmt.Sprintf("04%s %s", strings.ToUpper(address[2:]), strings.ToUpper(value))
+	return fmt.Sprintf("04%s %s", maskedAddress(address), strings.ToUpper(value))
 }
 
 func generateBranchCodeLine(address, targetAddress string, shouldLink bool) string {
@@ -188,7 +464,7 @@ func generateBranchCodeLine(address, targetAddress string, shouldLink bool) stri
 	addressDiffStr := fmt.Sprintf("%06X", addressDiff)
 	addressDiffStr = addressDiffStr[len(addressDiffStr)-6:]
 
-	return fmt.Sprintf("04%s %s%s", strings.ToUpper(address[2:]), prefix, addressDiffStr)
+	return fmt.Sprintf("04%s %s%s", maskedAddress(address), prefix, addressDiffStr)
 }
 
 func addLineAnnotation(line, annotation string) string {
@@ -199,14 +475,46 @@ func addLineAnnotation(line, annotation string) string {
 	return fmt.Sprintf("%s #%s", line, annotation)
 }
 
+// injectionJob is one .asm file discovered under an InjectFolder, paired
+// with the injection address parsed from its first line.
+type injectionJob struct {
+	address  string
+	filePath string
+}
+
+// generateInjectionFolderLines walks folder (and, if isRecursive, every
+// subfolder) for .asm files, compiles all of them concurrently through the
+// worker pool, and stitches each file's lines back together in the same
+// order the directory walk would have produced them serially.
 func generateInjectionFolderLines(folder string, isRecursive bool) []string {
+	jobs := collectInjectionJobs(folder, isRecursive)
+
+	instructions := make([][]byte, len(jobs))
+	parallelFor(len(jobs), func(i int) {
+		instructions[i] = timedCompile(jobs[i].filePath, addressToUint32(jobs[i].address))
+	})
+
 	lines := []string{}
+	for i, job := range jobs {
+		fileLines := generateInjectionCodeLines(job.address, instructions[i], job.filePath)
+		fileLines[0] = addLineAnnotation(fileLines[0], job.filePath)
+		lines = append(lines, fileLines...)
+	}
 
+	return lines
+}
+
+// collectInjectionJobs walks folder the same way generateInjectionFolderLines
+// used to, but only discovers files and their injection addresses - it
+// leaves compiling them to the caller so that can happen concurrently.
+func collectInjectionJobs(folder string, isRecursive bool) []injectionJob {
 	contents, err := ioutil.ReadDir(folder)
 	if err != nil {
 		log.Panic("Failed to read directory.", err)
 	}
 
+	jobs := []injectionJob{}
+
 	for _, file := range contents {
 		fileName := file.Name()
 		ext := filepath.Ext(fileName)
@@ -214,75 +522,76 @@ func generateInjectionFolderLines(folder string, isRecursive bool) []string {
 			continue
 		}
 
-		// Get full filepath for file
 		filePath := filepath.Join(folder, fileName)
+		jobs = append(jobs, injectionJob{
+			address:  readInjectionAddress(filePath),
+			filePath: filePath,
+		})
+	}
 
-		file, err := os.Open(filePath)
-		if err != nil {
-			log.Panicf("Failed to read file at %s\n%s\n", filePath, err.Error())
-		}
-		defer file.Close()
-
-		// Read first line from file to get address
-		scanner := bufio.NewScanner(file)
-		scanner.Scan()
-		firstLine := scanner.Text()
-
-		// Prepare injection address error
-		indicateAddressError := func(errStr ...string) {
-			errMsg := fmt.Sprintf(
-				"File at %s needs to specify the 4 byte injection address "+
-					"at the end of the first line of the file\n",
-				filePath,
-			)
-
-			if len(errStr) > 0 {
-				errMsg += errStr[0] + "\n"
+	if isRecursive {
+		// If we are recursively searching folders, process sub-directories
+		for _, file := range contents {
+			if !file.IsDir() {
+				continue
 			}
 
-			log.Panic(errMsg)
+			folderPath := filepath.Join(folder, file.Name())
+			jobs = append(jobs, collectInjectionJobs(folderPath, isRecursive)...)
 		}
+	}
 
-		// Get address
-		lineLength := len(firstLine)
-		if lineLength < 8 {
-			indicateAddressError()
-		}
-		address := firstLine[lineLength-8:]
+	return jobs
+}
 
-		_, err = hex.DecodeString(address)
-		if err != nil {
-			indicateAddressError(err.Error())
+// readInjectionAddress reads the 4 byte injection address every InjectFolder
+// .asm file must specify at the end of its first line.
+func readInjectionAddress(filePath string) string {
+	file, err := os.Open(filePath)
+	if err != nil {
+		log.Panicf("Failed to read file at %s\n%s\n", filePath, err.Error())
+	}
+	defer file.Close()
+
+	// Read first line from file to get address
+	scanner := bufio.NewScanner(file)
+	scanner.Scan()
+	firstLine := scanner.Text()
+
+	// Prepare injection address error
+	indicateAddressError := func(errStr ...string) {
+		errMsg := fmt.Sprintf(
+			"File at %s needs to specify the 4 byte injection address "+
+				"at the end of the first line of the file\n",
+			filePath,
+		)
+
+		if len(errStr) > 0 {
+			errMsg += errStr[0] + "\n"
 		}
 
-		// Compile file and add lines
-		fileLines := generateInjectionCodeLines(address, filePath)
-		fileLines[0] = addLineAnnotation(fileLines[0], filePath)
-		lines = append(lines, fileLines...)
+		log.Panic(errMsg)
 	}
 
-	if isRecursive {
-		// If we are recursively searching folders, process sub-directories
-		for _, file := range contents {
-			if !file.IsDir() {
-				continue
-			}
+	// Get address
+	lineLength := len(firstLine)
+	if lineLength < 8 {
+		indicateAddressError()
+	}
+	address := firstLine[lineLength-8:]
 
-			folderName := file.Name()
-			folderPath := filepath.Join(folder, folderName)
-			folderLines := generateInjectionFolderLines(folderPath, isRecursive)
-			lines = append(lines, folderLines...)
-		}
+	_, err = hex.DecodeString(address)
+	if err != nil {
+		indicateAddressError(err.Error())
 	}
 
-	return lines
+	return address
 }
 
-func generateInjectionCodeLines(address, file string) []string {
+func generateInjectionCodeLines(address string, instructions []byte, file string) []string {
 	// TODO: Add error if address or value is incorrect length/format
 	lines := []string{}
 
-	instructions := compile(file)
 	instructionLen := len(instructions)
 
 	if instructionLen == 0 {
@@ -305,7 +614,7 @@ func generateInjectionCodeLines(address, file string) []string {
 		instructions = append(instructions, 0x00, 0x00, 0x00, 0x00)
 	}
 
-	lines = append(lines, fmt.Sprintf("C2%s %08X", strings.ToUpper(address[2:]), len(instructions)/8))
+	lines = append(lines, fmt.Sprintf("C2%s %08X", maskedAddress(address), len(instructions)/8))
 
 	for i := 0; i < len(instructions); i += 8 {
 		left := strings.ToUpper(hex.EncodeToString(instructions[i : i+4]))
@@ -316,18 +625,16 @@ func generateInjectionCodeLines(address, file string) []string {
 	return lines
 }
 
-func generateReplaceCodeBlockLines(address, file string) []string {
+func generateReplaceCodeBlockLines(address string, instructions []byte, file string) []string {
 	// TODO: Add error if address or value is incorrect length/format
 	lines := []string{}
 
-	instructions := compile(file)
-
 	// Fixes code to have an even number of words
 	if len(instructions)%8 != 0 {
 		instructions = append(instructions, 0x60, 0x00, 0x00, 0x00)
 	}
 
-	lines = append(lines, fmt.Sprintf("06%s %08X", strings.ToUpper(address[2:]), len(instructions)))
+	lines = append(lines, fmt.Sprintf("06%s %08X", maskedAddress(address), len(instructions)))
 
 	for i := 0; i < len(instructions); i += 8 {
 		left := strings.ToUpper(hex.EncodeToString(instructions[i : i+4]))
@@ -354,7 +661,7 @@ func generateReplaceBinaryLines(address, file string) []string {
 		instructions = append(instructions, 0x60, 0x00, 0x00, 0x00)
 	}
 
-	lines = append(lines, fmt.Sprintf("06%s %08X", strings.ToUpper(address[2:]), len(instructions)))
+	lines = append(lines, fmt.Sprintf("06%s %08X", maskedAddress(address), len(instructions)))
 
 	for i := 0; i < len(instructions); i += 8 {
 		left := strings.ToUpper(hex.EncodeToString(instructions[i : i+4]))
@@ -365,8 +672,58 @@ func generateReplaceBinaryLines(address, file string) []string {
 	return lines
 }
 
-func compile(file string) []byte {
-	defer os.Remove("a.out")
+func compile(file string, baseAddress uint32) []byte {
+	if instructions, ok := loadCompileCache(file, baseAddress); ok {
+		return instructions
+	}
+
+	var instructions []byte
+	if assembler == "native" {
+		instructions = compileNative(file, baseAddress)
+	} else {
+		instructions = compileExternal(file)
+	}
+
+	storeCompileCache(file, baseAddress, instructions)
+	return instructions
+}
+
+// compileNative assembles file with the built-in PPC assembler, avoiding
+// the temp-file round trip and external toolchain dependency compileExternal
+// still needs. linkerSymbols is seeded into the assembler's symbol table so
+// the source can reference project-wide symbols/library exports by name,
+// and baseAddress anchors its layout to where the code actually runs from
+// so a `bl` to an absolute symbol encodes a correct displacement.
+//
+// For Inject/ExecuteAsm bodies this is still only an approximation, since
+// the Gecko codehandler places their real runtime PC in its own codelist
+// RAM rather than at geckoCode.Address - projects targeting those should
+// prefer an absolute load (lis/ori + mtctr/bctrl) over `bl`/`b` to a
+// library symbol, the same workaround real Gecko codehandler asm uses.
+func compileNative(file string, baseAddress uint32) []byte {
+	result, err := ppcas.AssembleFileWithOptions(file, ppcas.Options{
+		BaseAddress: baseAddress,
+		Predefined:  linkerSymbols,
+	})
+	if err != nil {
+		log.Panicf("Failed to assemble file: %s\n%s\n", file, err.Error())
+	}
+
+	return result.Code
+}
+
+func compileExternal(file string) []byte {
+	// Each call gets its own working directory so concurrent compiles
+	// (one per worker) never race over the same asm-to-compile.asm/a.out
+	// filenames.
+	workDir, err := os.MkdirTemp("", "gecko-compile")
+	if err != nil {
+		log.Panicf("Failed to create temporary compile directory\n%s\n", err.Error())
+	}
+	defer os.RemoveAll(workDir)
+
+	asmPath := filepath.Join(workDir, "asm-to-compile.asm")
+	outPath := filepath.Join(workDir, "a.out")
 
 	// First we are gonna load all the data from file and write it into temp file
 	// Technically this shouldn't be necessary but for some reason if the last line
@@ -379,21 +736,23 @@ func compile(file string) []byte {
 
 	// Explicitly add a new line at the end of the file, which should prevent line skip
 	asmContents = append(asmContents, []byte("\r\n")...)
-	err = ioutil.WriteFile("asm-to-compile.asm", asmContents, 0644)
+	err = ioutil.WriteFile(asmPath, asmContents, 0644)
 	if err != nil {
 		log.Panicf("Failed to write temporary asm file\n%s\n", err.Error())
 	}
-	defer os.Remove("asm-to-compile.asm")
 
 	if runtime.GOOS == "windows" {
-		cmd := exec.Command("powerpc-gekko-as.exe", "-a32", "-mbig", "-mregnames", "-mgekko", "asm-to-compile.asm")
+		args := append([]string{"-a32", "-mbig", "-mregnames", "-mgekko"}, defsymArgs()...)
+		args = append(args, "asm-to-compile.asm")
+		cmd := exec.Command("powerpc-gekko-as.exe", args...)
+		cmd.Dir = workDir
 		output, err := cmd.CombinedOutput()
 		if err != nil {
 			fmt.Printf("Failed to compile file: %s\n", file)
 			fmt.Printf("%s", output)
 			os.Exit(1)
 		}
-		contents, err := ioutil.ReadFile("a.out")
+		contents, err := ioutil.ReadFile(outPath)
 		if err != nil {
 			log.Panicf("Failed to read compiled file %s\n%s\n", file, err.Error())
 		}
@@ -405,7 +764,10 @@ func compile(file string) []byte {
 
 	// Just pray that powerpc-eabi-{as,objcopy} are in the user's $PATH, lol
 	if runtime.GOOS == "linux" || runtime.GOOS == "darwin" {
-		cmd := exec.Command("powerpc-eabi-as", "-a32", "-mbig", "-mregnames", "asm-to-compile.asm")
+		args := append([]string{"-a32", "-mbig", "-mregnames"}, defsymArgs()...)
+		args = append(args, "asm-to-compile.asm")
+		cmd := exec.Command("powerpc-eabi-as", args...)
+		cmd.Dir = workDir
 		output, err := cmd.CombinedOutput()
 		if err != nil {
 			fmt.Printf("Failed to compile file: %s\n", file)
@@ -413,13 +775,14 @@ func compile(file string) []byte {
 			os.Exit(1)
 		}
 		cmd = exec.Command("powerpc-eabi-objcopy", "-O", "binary", "a.out", "a.out")
+		cmd.Dir = workDir
 		output, err = cmd.CombinedOutput()
 		if err != nil {
 			fmt.Printf("Failed to pull out .text section: %s\n", file)
 			fmt.Printf("%s", output)
 			os.Exit(1)
 		}
-		contents, err := ioutil.ReadFile("a.out")
+		contents, err := ioutil.ReadFile(outPath)
 		if err != nil {
 			log.Panicf("Failed to read compiled file %s\n%s\n", file, err.Error())
 		}
@@ -431,12 +794,18 @@ func compile(file string) []byte {
 	return nil
 }
 
-func writeOutput(outputFile string) {
+func writeOutput(outputFile, textFormat string) {
 	fmt.Printf("Writing to %s...\n", outputFile)
 	ext := filepath.Ext(outputFile)
-	switch ext {
-	case ".gct":
+	switch {
+	case ext == ".gct":
 		writeGctOutput(outputFile)
+	case ext == ".ini":
+		writeIniOutput(outputFile)
+	case ext == ".xml":
+		writeXmlOutput(outputFile)
+	case textFormat == "ocarina":
+		writeOcarinaOutput(outputFile)
 	default:
 		writeTextOutput(outputFile)
 	}
@@ -444,29 +813,107 @@ func writeOutput(outputFile string) {
 	fmt.Printf("Successfuly wrote codes to %s\n", outputFile)
 }
 
+// renderCodeLines re-joins an entry's parallel Codes/Annotations back into
+// the "TTAAAAAA VVVVVVVV #annotation" text form.
+func renderCodeLines(entry OutputEntry) []string {
+	lines := make([]string, len(entry.Codes))
+	for i, code := range entry.Codes {
+		lines[i] = addLineAnnotation(code, entry.Annotations[i])
+	}
+	return lines
+}
+
 func writeTextOutput(outputFile string) {
-	fullText := strings.Join(output, "\n")
+	lines := []string{}
+
+	for _, entry := range output {
+		lines = append(lines, renderHeaderLines(entry.Header)...)
+		lines = append(lines, renderCodeLines(entry)...)
+		lines = append(lines, "")
+	}
+
+	fullText := strings.Join(lines, "\n")
+	ioutil.WriteFile(outputFile, []byte(fullText), 0644)
+}
+
+// writeOcarinaOutput writes the same header/description/hex-line body as
+// writeTextOutput, wrapped in the "[Gecko Codes]" banner line Ocarina and
+// GCT-RAM-Manager (GCTRM) expect at the top of an importable .txt code
+// dump. Selected by setting Config.TextFormat to "ocarina".
+func writeOcarinaOutput(outputFile string) {
+	lines := []string{"[Gecko Codes]", ""}
+
+	for _, entry := range output {
+		lines = append(lines, renderHeaderLines(entry.Header)...)
+		lines = append(lines, renderCodeLines(entry)...)
+		lines = append(lines, "")
+	}
+
+	fullText := strings.Join(lines, "\n")
 	ioutil.WriteFile(outputFile, []byte(fullText), 0644)
 }
 
 func writeGctOutput(outputFile string) {
 	gctBytes := []byte{0x00, 0xD0, 0xC0, 0xDE, 0x00, 0xD0, 0xC0, 0xDE}
 
-	for _, line := range output {
-		if len(line) < 17 {
-			// lines with less than 17 characters cannot be code lines
-			continue
-		}
-
-		lineBytes, err := hex.DecodeString(line[0:8] + line[9:17])
-		if err != nil {
-			// If parse fails that likely means this is a header or something
-			continue
+	for _, entry := range output {
+		for _, code := range entry.Codes {
+			lineBytes, err := hex.DecodeString(strings.ReplaceAll(code, " ", ""))
+			if err != nil {
+				log.Panicf("Failed to decode code line %q\n%s\n", code, err.Error())
+			}
+			gctBytes = append(gctBytes, lineBytes...)
 		}
-
-		gctBytes = append(gctBytes, lineBytes...)
 	}
 
 	gctBytes = append(gctBytes, 0xF0, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00)
 	ioutil.WriteFile(outputFile, gctBytes, 0644)
 }
+
+// writeIniOutput writes a Dolphin GameSettings-style ini: a [Gecko]
+// section with every code's header/description/hex lines, followed by a
+// [Gecko_Enabled] section listing each code's header line again so
+// Dolphin defaults them to enabled.
+func writeIniOutput(outputFile string) {
+	lines := []string{"[Gecko]"}
+
+	for _, entry := range output {
+		lines = append(lines, renderHeaderLines(entry.Header)...)
+		lines = append(lines, renderCodeLines(entry)...)
+		lines = append(lines, "")
+	}
+
+	lines = append(lines, "[Gecko_Enabled]")
+	for _, entry := range output {
+		lines = append(lines, fmt.Sprintf("$%s", entry.Header.Name))
+	}
+
+	ioutil.WriteFile(outputFile, []byte(strings.Join(lines, "\n")+"\n"), 0644)
+}
+
+// writeXmlOutput writes a Nintendont/USB Loader GX style cheat DB: one
+// <cheat> element per code, its hex lines joined into a <codes> block.
+func writeXmlOutput(outputFile string) {
+	lines := []string{"<wiidb>", "  <game>", "    <cheats>"}
+
+	for _, entry := range output {
+		lines = append(lines, "      <cheat status=\"disabled\">")
+		lines = append(lines, fmt.Sprintf("        <name>%s</name>", xmlEscape(entry.Header.Name)))
+		lines = append(lines, fmt.Sprintf("        <creator>%s</creator>", xmlEscape(strings.Join(entry.Header.Authors, ", "))))
+		lines = append(lines, fmt.Sprintf("        <comment>%s</comment>", xmlEscape(strings.Join(entry.Header.Description, " "))))
+		lines = append(lines, "        <codes>"+xmlEscape(strings.Join(entry.Codes, "\n"))+"</codes>")
+		lines = append(lines, "      </cheat>")
+	}
+
+	lines = append(lines, "    </cheats>", "  </game>", "</wiidb>")
+
+	ioutil.WriteFile(outputFile, []byte(strings.Join(lines, "\n")+"\n"), 0644)
+}
+
+// xmlEscape escapes the handful of characters that matter inside XML text
+// content; names/authors/comments come from codes.json so this is just
+// defensive against stray "&"/"<"/">" rather than a general XML encoder.
+func xmlEscape(s string) string {
+	replacer := strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+	return replacer.Replace(s)
+}
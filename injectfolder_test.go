@@ -0,0 +1,66 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+)
+
+// TestGenerateInjectionFolderLinesOrdering drives generateInjectionFolderLines
+// over a real InjectFolder with more files than worker slots, so jobs finish
+// out of order under the concurrent compile pool, and checks the stitched
+// lines still come back in collectInjectionJobs' (sorted-by-filename) order
+// rather than whichever goroutine happened to finish first.
+func TestGenerateInjectionFolderLinesOrdering(t *testing.T) {
+	assembler = "native"
+	workerSem = make(chan struct{}, 2)
+
+	lines := generateInjectionFolderLines("testdata/injectfolder", false)
+
+	want := []struct {
+		code       string
+		annotation string
+	}{
+		{"04" + maskedAddress("80001000") + " 3860AAAA", "testdata/injectfolder/a.asm"},
+		{"04" + maskedAddress("80001004") + " 3860BBBB", "testdata/injectfolder/b.asm"},
+		{"04" + maskedAddress("80001008") + " 3860CCCC", "testdata/injectfolder/c.asm"},
+	}
+
+	if len(lines) != len(want) {
+		t.Fatalf("got %d lines, want %d: %v", len(lines), len(want), lines)
+	}
+
+	for i, line := range lines {
+		code, annotation := splitCodeLine(line)
+		if code != want[i].code {
+			t.Errorf("line %d: got code %q, want %q", i, code, want[i].code)
+		}
+		if annotation != want[i].annotation {
+			t.Errorf("line %d: got annotation %q, want %q", i, annotation, want[i].annotation)
+		}
+	}
+}
+
+// TestParallelForRecoversWorkerPanic guards against a worker's panic (how
+// compile()/compileNative()/compileExternal() report failures) crashing the
+// whole process with a raw Go stack trace instead of surfacing on the
+// calling goroutine, where main()'s top-level recover can turn it into the
+// tool's usual clean one-line error.
+func TestParallelForRecoversWorkerPanic(t *testing.T) {
+	workerSem = make(chan struct{}, 2)
+
+	defer func() {
+		r := recover()
+		if r == nil {
+			t.Fatal("expected parallelFor to re-panic the worker's failure on the calling goroutine")
+		}
+		if r != "boom at index 2" {
+			t.Errorf("recovered panic value = %v, want %q", r, "boom at index 2")
+		}
+	}()
+
+	parallelFor(5, func(i int) {
+		if i == 2 {
+			panic(fmt.Sprintf("boom at index %d", i))
+		}
+	})
+}
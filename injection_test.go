@@ -0,0 +1,36 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+// TestInjections wires every *.geckotest.json fixture under testdata/ into
+// `go test ./...`, so a modder's injection regression tests run the same
+// way as `gecko test` without a separate invocation.
+func TestInjections(t *testing.T) {
+	files := collectTestFiles("testdata")
+	if len(files) == 0 {
+		t.Skip("no " + testFileSuffix + " files found under testdata")
+	}
+
+	for _, file := range files {
+		file := file
+		test, err := readInjectionTestFile(file)
+		if err != nil {
+			t.Errorf("%s: %s", file, err)
+			continue
+		}
+
+		name := test.Name
+		if name == "" {
+			name = file
+		}
+
+		t.Run(name, func(t *testing.T) {
+			if err := RunInjectionTest(filepath.Dir(file), test); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
@@ -0,0 +1,592 @@
+package ppcas
+
+import (
+	"fmt"
+	"strings"
+)
+
+// encodeInstruction turns one parsed statement into its 32-bit big-endian
+// instruction word. Only the PPC/Broadway/Gekko subset that shows up in
+// typical Gecko code injections is implemented; anything else reports an
+// unsupported-mnemonic error rather than silently emitting garbage.
+func encodeInstruction(st *statement, symbols map[string]uint32) (uint32, error) {
+	enc, ok := encoders[st.op]
+	if !ok {
+		return 0, &Error{st.line, fmt.Sprintf("unsupported or unknown mnemonic %q", st.op)}
+	}
+	word, err := enc(st.args, st.addr, symbols)
+	if err != nil {
+		return 0, &Error{st.line, err.Error()}
+	}
+	return word, nil
+}
+
+type encoder func(args []string, addr uint32, symbols map[string]uint32) (uint32, error)
+
+// rcSuffix reports whether a mnemonic ends in "." (the CR0-updating form)
+// and returns the bare mnemonic alongside the Rc bit to OR into the word.
+func rcSuffix(op string) (string, uint32) {
+	if strings.HasSuffix(op, ".") {
+		return strings.TrimSuffix(op, "."), 1
+	}
+	return op, 0
+}
+
+func reg(args []string, i int, resolve func(string) (uint32, bool)) (uint32, error) {
+	if i >= len(args) {
+		return 0, fmt.Errorf("missing operand %d", i+1)
+	}
+	v, ok := resolve(args[i])
+	if !ok {
+		return 0, fmt.Errorf("invalid register operand %q", args[i])
+	}
+	return v, nil
+}
+
+func imm(args []string, i int, symbols map[string]uint32) (uint32, error) {
+	if i >= len(args) {
+		return 0, fmt.Errorf("missing operand %d", i+1)
+	}
+	return evalExpr(args[i], symbols)
+}
+
+// mem parses a `d(rA)` style memory operand, as used by loads and stores.
+func mem(arg string, symbols map[string]uint32) (disp uint32, ra uint32, err error) {
+	open := strings.Index(arg, "(")
+	if open < 0 || !strings.HasSuffix(arg, ")") {
+		return 0, 0, fmt.Errorf("expected d(rA) operand, got %q", arg)
+	}
+	dispStr := strings.TrimSpace(arg[:open])
+	regStr := strings.TrimSpace(arg[open+1 : len(arg)-1])
+
+	disp, err = evalExpr(dispStr, symbols)
+	if err != nil {
+		return 0, 0, err
+	}
+	ra, ok := gpr(regStr)
+	if !ok {
+		return 0, 0, fmt.Errorf("invalid base register %q", regStr)
+	}
+	return disp, ra, nil
+}
+
+func dForm(opcode uint32) encoder {
+	return func(args []string, addr uint32, symbols map[string]uint32) (uint32, error) {
+		rt, err := reg(args, 0, gpr)
+		if err != nil {
+			return 0, err
+		}
+		ra, err := reg(args, 1, gpr)
+		if err != nil {
+			return 0, err
+		}
+		simm, err := imm(args, 2, symbols)
+		if err != nil {
+			return 0, err
+		}
+		return opcode<<26 | rt<<21 | ra<<16 | (simm & 0xffff), nil
+	}
+}
+
+// dFormLogicalImm handles the ori/oris/xori/xoris/andi./andis. family: unlike
+// the arithmetic D-form group, their syntax is `rA, rS, UIMM` with the
+// source register in field1 (bits 21-25) and the destination in field2
+// (bits 16-20) — the mirror image of dForm's field assignment, matching
+// logicalX below and internal/ppcsim's execLogicalImm.
+func dFormLogicalImm(opcode uint32) encoder {
+	return func(args []string, addr uint32, symbols map[string]uint32) (uint32, error) {
+		ra, err := reg(args, 0, gpr)
+		if err != nil {
+			return 0, err
+		}
+		rs, err := reg(args, 1, gpr)
+		if err != nil {
+			return 0, err
+		}
+		uimm, err := imm(args, 2, symbols)
+		if err != nil {
+			return 0, err
+		}
+		return opcode<<26 | rs<<21 | ra<<16 | (uimm & 0xffff), nil
+	}
+}
+
+// dFormStore handles store-like D-form instructions whose syntax is
+// `rS, d(rA)` instead of `rT, rA, SIMM`.
+func dFormMem(opcode uint32) encoder {
+	return func(args []string, addr uint32, symbols map[string]uint32) (uint32, error) {
+		if len(args) != 2 {
+			return 0, fmt.Errorf("expected 2 operands, got %d", len(args))
+		}
+		rt, ok := gpr(args[0])
+		if !ok {
+			return 0, fmt.Errorf("invalid register operand %q", args[0])
+		}
+		disp, ra, err := mem(args[1], symbols)
+		if err != nil {
+			return 0, err
+		}
+		return opcode<<26 | rt<<21 | ra<<16 | (disp & 0xffff), nil
+	}
+}
+
+func xForm(opcode, xo, rc uint32) encoder {
+	return func(args []string, addr uint32, symbols map[string]uint32) (uint32, error) {
+		rs, err := reg(args, 0, gpr)
+		if err != nil {
+			return 0, err
+		}
+		ra, err := reg(args, 1, gpr)
+		if err != nil {
+			return 0, err
+		}
+		rb, err := reg(args, 2, gpr)
+		if err != nil {
+			return 0, err
+		}
+		return opcode<<26 | rs<<21 | ra<<16 | rb<<11 | xo<<1 | rc, nil
+	}
+}
+
+// xFormMem handles indexed loads/stores of the form `rT, rA, rB`.
+func xFormMem(opcode, xo uint32) encoder {
+	return func(args []string, addr uint32, symbols map[string]uint32) (uint32, error) {
+		rt, err := reg(args, 0, gpr)
+		if err != nil {
+			return 0, err
+		}
+		ra, err := reg(args, 1, gpr)
+		if err != nil {
+			return 0, err
+		}
+		rb, err := reg(args, 2, gpr)
+		if err != nil {
+			return 0, err
+		}
+		return opcode<<26 | rt<<21 | ra<<16 | rb<<11 | xo<<1, nil
+	}
+}
+
+func xoForm(opcode, xo, rc, oe uint32) encoder {
+	return func(args []string, addr uint32, symbols map[string]uint32) (uint32, error) {
+		rt, err := reg(args, 0, gpr)
+		if err != nil {
+			return 0, err
+		}
+		ra, err := reg(args, 1, gpr)
+		if err != nil {
+			return 0, err
+		}
+		rb, err := reg(args, 2, gpr)
+		if err != nil {
+			return 0, err
+		}
+		return opcode<<26 | rt<<21 | ra<<16 | rb<<11 | oe<<10 | xo<<1 | rc, nil
+	}
+}
+
+// unaryX handles X-form instructions with a single source register,
+// e.g. neg, extsb, extsh, mr-likes (rT, rA).
+func unaryX(opcode, xo, rc uint32) encoder {
+	return func(args []string, addr uint32, symbols map[string]uint32) (uint32, error) {
+		rt, err := reg(args, 0, gpr)
+		if err != nil {
+			return 0, err
+		}
+		ra, err := reg(args, 1, gpr)
+		if err != nil {
+			return 0, err
+		}
+		return opcode<<26 | rt<<21 | ra<<16 | xo<<1 | rc, nil
+	}
+}
+
+func branchI(aa, lk uint32) encoder {
+	return func(args []string, addr uint32, symbols map[string]uint32) (uint32, error) {
+		target, err := imm(args, 0, symbols)
+		if err != nil {
+			return 0, err
+		}
+		var li uint32
+		if aa == 1 {
+			li = target
+		} else {
+			li = target - addr
+		}
+		return 18<<26 | (li & 0x3fffffc) | aa<<1 | lk, nil
+	}
+}
+
+// condBranch encodes the common `b<cond>` mnemonics (beq, bne, blt, ...) as
+// B-form branches against a condition register field, defaulting to cr0
+// when no explicit crN is given as a trailing operand.
+func condBranch(bo uint32, bi uint32, aa, lk uint32) encoder {
+	return func(args []string, addr uint32, symbols map[string]uint32) (uint32, error) {
+		crBase := bi
+		target := 0
+		if len(args) == 2 {
+			cr, ok := crf(args[0])
+			if !ok {
+				return 0, fmt.Errorf("invalid condition register %q", args[0])
+			}
+			crBase = cr*4 + bi
+			target = 1
+		} else if len(args) != 1 {
+			return 0, fmt.Errorf("expected 1 or 2 operands, got %d", len(args))
+		}
+
+		dest, err := imm(args, target, symbols)
+		if err != nil {
+			return 0, err
+		}
+		var bd uint32
+		if aa == 1 {
+			bd = dest
+		} else {
+			bd = dest - addr
+		}
+		return 16<<26 | bo<<21 | crBase<<16 | (bd & 0xfffc) | aa<<1 | lk, nil
+	}
+}
+
+func noArgs(word uint32) encoder {
+	return func(args []string, addr uint32, symbols map[string]uint32) (uint32, error) {
+		return word, nil
+	}
+}
+
+func sprForm(xo uint32, toSpr bool) encoder {
+	return func(args []string, addr uint32, symbols map[string]uint32) (uint32, error) {
+		if len(args) != 2 {
+			return 0, fmt.Errorf("expected 2 operands, got %d", len(args))
+		}
+		var rt string
+		var sprTok string
+		if toSpr {
+			sprTok, rt = args[0], args[1]
+		} else {
+			rt, sprTok = args[0], args[1]
+		}
+		r, ok := gpr(rt)
+		if !ok {
+			return 0, fmt.Errorf("invalid register operand %q", rt)
+		}
+		s, ok := spr(sprTok)
+		if !ok {
+			return 0, fmt.Errorf("unknown special purpose register %q", sprTok)
+		}
+		return 31<<26 | r<<21 | s<<11 | xo<<1, nil
+	}
+}
+
+// fixedSprForm handles the mtlr/mflr/mtctr/mfctr aliases: unlike mtspr/
+// mfspr, the target SPR is implied by the mnemonic itself, so the only
+// operand is the GPR (`mtlr r4`, not `mtlr lr, r4`).
+func fixedSprForm(sprName string, xo uint32) encoder {
+	return func(args []string, addr uint32, symbols map[string]uint32) (uint32, error) {
+		if len(args) != 1 {
+			return 0, fmt.Errorf("expected 1 operand, got %d", len(args))
+		}
+		r, ok := gpr(args[0])
+		if !ok {
+			return 0, fmt.Errorf("invalid register operand %q", args[0])
+		}
+		s, ok := spr(sprName)
+		if !ok {
+			return 0, fmt.Errorf("unknown special purpose register %q", sprName)
+		}
+		return 31<<26 | r<<21 | s<<11 | xo<<1, nil
+	}
+}
+
+// psqForm handles the Gekko-only psq_l/psq_st paired-single load/store,
+// which take an extra quantized-format W/I pair beyond the plain d(rA).
+func psqForm(opcode uint32) encoder {
+	return func(args []string, addr uint32, symbols map[string]uint32) (uint32, error) {
+		if len(args) != 2 {
+			return 0, fmt.Errorf("expected 2 operands, got %d", len(args))
+		}
+		frt, ok := fpr(args[0])
+		if !ok {
+			return 0, fmt.Errorf("invalid float register operand %q", args[0])
+		}
+		disp, ra, err := mem(args[1], symbols)
+		if err != nil {
+			return 0, err
+		}
+		// W=0 (paired), I=0 (quantization register qr0): the common case
+		// for simple injections that don't use scaled quantization.
+		return opcode<<26 | frt<<21 | ra<<16 | (disp & 0xfff), nil
+	}
+}
+
+// psUnaryForm handles the single-source paired-single ops (ps_neg, ps_mr),
+// which take floating-point register operands (frT, frA) like psForm
+// rather than unaryX's GPR operands.
+func psUnaryForm(opcode, xo, rc uint32) encoder {
+	return func(args []string, addr uint32, symbols map[string]uint32) (uint32, error) {
+		frt, err := reg(args, 0, fpr)
+		if err != nil {
+			return 0, err
+		}
+		fra, err := reg(args, 1, fpr)
+		if err != nil {
+			return 0, err
+		}
+		return opcode<<26 | frt<<21 | fra<<16 | xo<<1 | rc, nil
+	}
+}
+
+func psForm(opcode, xo, rc uint32) encoder {
+	return func(args []string, addr uint32, symbols map[string]uint32) (uint32, error) {
+		frt, err := reg(args, 0, fpr)
+		if err != nil {
+			return 0, err
+		}
+		fra, err := reg(args, 1, fpr)
+		if err != nil {
+			return 0, err
+		}
+		frb, err := reg(args, 2, fpr)
+		if err != nil {
+			return 0, err
+		}
+		return opcode<<26 | frt<<21 | fra<<16 | frb<<11 | xo<<1 | rc, nil
+	}
+}
+
+// encoders maps every supported mnemonic to its encoding function. "."
+// (Rc=1) suffixed variants are generated for the handful of instructions
+// that support them rather than hand-duplicated below.
+var encoders map[string]encoder
+
+func init() {
+	encoders = map[string]encoder{
+		// Integer arithmetic, D-form.
+		"addi":   dForm(14),
+		"addic":  dForm(12),
+		"addic.": dForm(13),
+		"addis":  dForm(15),
+		"mulli":  dForm(7),
+		"subfic": dForm(8),
+		"cmpwi": func(args []string, addr uint32, symbols map[string]uint32) (uint32, error) {
+			return cmpImm(11, 0, args, symbols)
+		},
+		"cmplwi": func(args []string, addr uint32, symbols map[string]uint32) (uint32, error) {
+			return cmpImm(10, 0, args, symbols)
+		},
+		"andi.":  dFormLogicalImm(28),
+		"andis.": dFormLogicalImm(29),
+		"ori":    dFormLogicalImm(24),
+		"oris":   dFormLogicalImm(25),
+		"xori":   dFormLogicalImm(26),
+		"xoris":  dFormLogicalImm(27),
+
+		// Pseudo-ops built from the D-form instructions above.
+		"li":  pseudoLoadImm(14),
+		"lis": pseudoLoadImm(15),
+		"nop": noArgs(0x60000000),
+		"mr":  pseudoMove(0),
+		"mr.": pseudoMove(1),
+
+		// Loads/stores (D-form).
+		"lwz":  dFormMem(32),
+		"lwzu": dFormMem(33),
+		"lbz":  dFormMem(34),
+		"lbzu": dFormMem(35),
+		"stw":  dFormMem(36),
+		"stwu": dFormMem(37),
+		"stb":  dFormMem(38),
+		"stbu": dFormMem(39),
+		"lhz":  dFormMem(40),
+		"lhzu": dFormMem(41),
+		"lha":  dFormMem(42),
+		"lhau": dFormMem(43),
+		"sth":  dFormMem(44),
+		"sthu": dFormMem(45),
+
+		// Indexed loads/stores (X-form).
+		"lwzx": xFormMem(31, 23),
+		"lbzx": xFormMem(31, 87),
+		"lhzx": xFormMem(31, 279),
+		"lhax": xFormMem(31, 343),
+		"stwx": xFormMem(31, 151),
+		"stbx": xFormMem(31, 215),
+		"sthx": xFormMem(31, 407),
+
+		// Branches.
+		"b":     branchI(0, 0),
+		"ba":    branchI(1, 0),
+		"bl":    branchI(0, 1),
+		"bla":   branchI(1, 1),
+		"blr":   noArgs(0x4e800020),
+		"blrl":  noArgs(0x4e800021),
+		"bctr":  noArgs(0x4e800420),
+		"bctrl": noArgs(0x4e800421),
+
+		"beq":  condBranch(12, 2, 0, 0),
+		"bne":  condBranch(4, 2, 0, 0),
+		"blt":  condBranch(12, 0, 0, 0),
+		"bgt":  condBranch(12, 1, 0, 0),
+		"ble":  condBranch(4, 1, 0, 0),
+		"bge":  condBranch(4, 0, 0, 0),
+		"beql": condBranch(12, 2, 0, 1),
+		"bnel": condBranch(4, 2, 0, 1),
+
+		// Compare, CR ops.
+		"mtlr":  fixedSprForm("lr", 467),
+		"mflr":  fixedSprForm("lr", 339),
+		"mtctr": fixedSprForm("ctr", 467),
+		"mfctr": fixedSprForm("ctr", 339),
+		"mtspr": sprForm(467, true),
+		"mfspr": sprForm(339, false),
+
+		// Gekko paired singles.
+		"ps_add": psForm(4, 21, 0),
+		"ps_sub": psForm(4, 20, 0),
+		"ps_mul": func(args []string, addr uint32, symbols map[string]uint32) (uint32, error) {
+			frt, err := reg(args, 0, fpr)
+			if err != nil {
+				return 0, err
+			}
+			fra, err := reg(args, 1, fpr)
+			if err != nil {
+				return 0, err
+			}
+			frc, err := reg(args, 2, fpr)
+			if err != nil {
+				return 0, err
+			}
+			return 4<<26 | frt<<21 | fra<<16 | frc<<6 | 25<<1, nil
+		},
+		"ps_neg": psUnaryForm(4, 40, 0),
+		"ps_mr":  psUnaryForm(4, 72, 0),
+		"psq_l":  psqForm(56),
+		"psq_st": psqForm(60),
+	}
+
+	// Arithmetic/logical instructions available in both a plain and a
+	// CR0-updating "." form, expressed as XO-form (rT, rA, rB).
+	for name, def := range map[string]struct{ xo, oe uint32 }{
+		"add":   {266, 0},
+		"addc":  {10, 0},
+		"adde":  {138, 0},
+		"subf":  {40, 0},
+		"subfc": {8, 0},
+		"subfe": {136, 0},
+		"mullw": {235, 0},
+		"divw":  {491, 0},
+	} {
+		n, d := name, def
+		encoders[n] = xoForm(31, d.xo, 0, d.oe)
+		encoders[n+"."] = xoForm(31, d.xo, 1, d.oe)
+	}
+
+	// Logical X-form instructions (rA, rS, rB) with a "." CR0-updating
+	// variant.
+	for name, xo := range map[string]uint32{
+		"and":  28,
+		"or":   444,
+		"xor":  316,
+		"nor":  124,
+		"nand": 476,
+		"andc": 60,
+		"orc":  412,
+		"eqv":  284,
+	} {
+		n, x := name, xo
+		encoders[n] = logicalX(31, x, 0)
+		encoders[n+"."] = logicalX(31, x, 1)
+	}
+
+	for name, xo := range map[string]uint32{
+		"neg":   104,
+		"extsb": 954,
+		"extsh": 922,
+	} {
+		n, x := name, xo
+		encoders[n] = unaryX(31, x, 0)
+		encoders[n+"."] = unaryX(31, x, 1)
+	}
+
+	for name, xo := range map[string]uint32{
+		"slw":  24,
+		"srw":  536,
+		"sraw": 792,
+	} {
+		n, x := name, xo
+		encoders[n] = logicalX(31, x, 0)
+		encoders[n+"."] = logicalX(31, x, 1)
+	}
+}
+
+// logicalX handles X-form instructions whose operand order is
+// `rA, rS, rB` (the destination comes from the opposite field to
+// arithmetic XO-form instructions).
+func logicalX(opcode, xo, rc uint32) encoder {
+	return func(args []string, addr uint32, symbols map[string]uint32) (uint32, error) {
+		ra, err := reg(args, 0, gpr)
+		if err != nil {
+			return 0, err
+		}
+		rs, err := reg(args, 1, gpr)
+		if err != nil {
+			return 0, err
+		}
+		rb, err := reg(args, 2, gpr)
+		if err != nil {
+			return 0, err
+		}
+		return opcode<<26 | rs<<21 | ra<<16 | rb<<11 | xo<<1 | rc, nil
+	}
+}
+
+func cmpImm(opcode, field uint32, args []string, symbols map[string]uint32) (uint32, error) {
+	if len(args) != 2 {
+		return 0, fmt.Errorf("expected 2 operands, got %d", len(args))
+	}
+	ra, ok := gpr(args[0])
+	if !ok {
+		return 0, fmt.Errorf("invalid register operand %q", args[0])
+	}
+	uimm, err := evalExpr(args[1], symbols)
+	if err != nil {
+		return 0, err
+	}
+	return opcode<<26 | field<<23 | ra<<16 | (uimm & 0xffff), nil
+}
+
+func pseudoLoadImm(opcode uint32) encoder {
+	return func(args []string, addr uint32, symbols map[string]uint32) (uint32, error) {
+		if len(args) != 2 {
+			return 0, fmt.Errorf("expected 2 operands, got %d", len(args))
+		}
+		rt, ok := gpr(args[0])
+		if !ok {
+			return 0, fmt.Errorf("invalid register operand %q", args[0])
+		}
+		simm, err := evalExpr(args[1], symbols)
+		if err != nil {
+			return 0, err
+		}
+		return opcode<<26 | rt<<21 | (simm & 0xffff), nil
+	}
+}
+
+// pseudoMove implements `mr`/`mr.` as the `or rA, rS, rS` it expands to.
+func pseudoMove(rc uint32) encoder {
+	return func(args []string, addr uint32, symbols map[string]uint32) (uint32, error) {
+		if len(args) != 2 {
+			return 0, fmt.Errorf("expected 2 operands, got %d", len(args))
+		}
+		rt, ok := gpr(args[0])
+		if !ok {
+			return 0, fmt.Errorf("invalid register operand %q", args[0])
+		}
+		rs, ok := gpr(args[1])
+		if !ok {
+			return 0, fmt.Errorf("invalid register operand %q", args[1])
+		}
+		return 31<<26 | rs<<21 | rt<<16 | rs<<11 | 444<<1 | rc, nil
+	}
+}
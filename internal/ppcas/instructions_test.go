@@ -0,0 +1,306 @@
+package ppcas_test
+
+import (
+	"testing"
+
+	"github.com/hosaka-corp/gecko/internal/ppcas"
+)
+
+func TestAssembleBranchForms(t *testing.T) {
+	tests := []struct {
+		name     string
+		src      string
+		wantWord uint32
+	}{
+		// b target (relative, no link): opcode 18, LI = target-addr, AA=0, LK=0.
+		{"b", "b 0x80001008", 18<<26 | 8},
+		// ba target (absolute): AA=1.
+		{"ba", "ba 0x80001008", 18<<26 | 0x80001008&0x3fffffc | 1<<1},
+		// bl target: LK=1.
+		{"bl", "bl 0x80001008", 18<<26 | 8 | 1},
+		// bla target: AA=1, LK=1.
+		{"bla", "bla 0x80001008", 18<<26 | 0x80001008&0x3fffffc | 1<<1 | 1},
+		{"blr", "blr", 0x4e800020},
+		{"blrl", "blrl", 0x4e800021},
+		{"bctr", "bctr", 0x4e800420},
+		{"bctrl", "bctrl", 0x4e800421},
+		// beq (BO=12, BI=2, i.e. cr0 EQ).
+		{"beq", "beq 0x80001008", 16<<26 | 12<<21 | 2<<16 | 8},
+		{"bne", "bne 0x80001008", 16<<26 | 4<<21 | 2<<16 | 8},
+		{"blt", "blt 0x80001008", 16<<26 | 12<<21 | 0<<16 | 8},
+		{"bgt", "bgt 0x80001008", 16<<26 | 12<<21 | 1<<16 | 8},
+		{"ble", "ble 0x80001008", 16<<26 | 4<<21 | 1<<16 | 8},
+		{"bge", "bge 0x80001008", 16<<26 | 4<<21 | 0<<16 | 8},
+		{"beql", "beql 0x80001008", 16<<26 | 12<<21 | 2<<16 | 8 | 1},
+		{"bnel", "bnel 0x80001008", 16<<26 | 4<<21 | 2<<16 | 8 | 1},
+	}
+
+	const base = 0x80001000
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := ppcas.AssembleWithOptions(tt.src, ppcas.Options{BaseAddress: base})
+			if err != nil {
+				t.Fatalf("failed to assemble %q: %v", tt.src, err)
+			}
+			w := words(t, result.Code)
+			if w[0] != tt.wantWord {
+				t.Errorf("got %#08x, want %#08x", w[0], tt.wantWord)
+			}
+		})
+	}
+}
+
+func TestAssembleBranchWithExplicitCRField(t *testing.T) {
+	// "beq cr1, target" should offset BI by the field (cr1 -> BI base 4).
+	result, err := ppcas.AssembleWithOptions("beq cr1, 0x80001008", ppcas.Options{BaseAddress: 0x80001000})
+	if err != nil {
+		t.Fatalf("failed to assemble: %v", err)
+	}
+	w := words(t, result.Code)
+	wantBI := uint32(1*4 + 2)
+	if gotBI := (w[0] >> 16) & 0x1f; gotBI != wantBI {
+		t.Errorf("BI = %d, want %d", gotBI, wantBI)
+	}
+}
+
+func TestAssembleFixedSprAliases(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		xo   uint32
+		spr  uint32 // the packed (swapped-half) SPR field the instruction should encode
+	}{
+		{"mtlr", "mtlr r4", 467, (8&0x1f)<<5 | (8 >> 5)},
+		{"mflr", "mflr r3", 339, (8&0x1f)<<5 | (8 >> 5)},
+		{"mtctr", "mtctr r4", 467, (9&0x1f)<<5 | (9 >> 5)},
+		{"mfctr", "mfctr r3", 339, (9&0x1f)<<5 | (9 >> 5)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := assemble(t, tt.src)
+			gotOpcode := w[0] >> 26
+			if gotOpcode != 31 {
+				t.Errorf("opcode = %d, want 31", gotOpcode)
+			}
+			gotXO := (w[0] >> 1) & 0x3ff
+			if gotXO != tt.xo {
+				t.Errorf("XO = %d, want %d", gotXO, tt.xo)
+			}
+			gotSpr := (w[0] >> 11) & 0x3ff
+			if gotSpr != tt.spr {
+				t.Errorf("spr field = %#x, want %#x", gotSpr, tt.spr)
+			}
+		})
+	}
+}
+
+func TestAssembleMtsprMfsprGenericForm(t *testing.T) {
+	// mtspr/mfspr (unlike their mtlr/mflr/mtctr/mfctr aliases) take the SPR
+	// name as an explicit first operand.
+	w := assemble(t, "mtspr ctr, r5")
+	if gotR := (w[0] >> 21) & 0x1f; gotR != 5 {
+		t.Errorf("source register field = %d, want 5", gotR)
+	}
+}
+
+func TestAssembleFixedSprAliasRejectsExtraOperand(t *testing.T) {
+	if _, err := ppcas.Assemble("mtlr lr, r4"); err == nil {
+		t.Fatal("expected mtlr with 2 operands to fail (the SPR is implied by the mnemonic)")
+	}
+}
+
+func TestAssembleIndexedLoadsAndStores(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		xo   uint32
+	}{
+		{"lwzx", "lwzx r3, r4, r5", 23},
+		{"lbzx", "lbzx r3, r4, r5", 87},
+		{"lhzx", "lhzx r3, r4, r5", 279},
+		{"lhax", "lhax r3, r4, r5", 343},
+		{"stwx", "stwx r3, r4, r5", 151},
+		{"stbx", "stbx r3, r4, r5", 215},
+		{"sthx", "sthx r3, r4, r5", 407},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := assemble(t, tt.src)
+			if gotOpcode := w[0] >> 26; gotOpcode != 31 {
+				t.Errorf("opcode = %d, want 31", gotOpcode)
+			}
+			if gotXO := (w[0] >> 1) & 0x3ff; gotXO != tt.xo {
+				t.Errorf("XO = %d, want %d", gotXO, tt.xo)
+			}
+			if gotRT := (w[0] >> 21) & 0x1f; gotRT != 3 {
+				t.Errorf("rT field = %d, want 3", gotRT)
+			}
+			if gotRA := (w[0] >> 16) & 0x1f; gotRA != 4 {
+				t.Errorf("rA field = %d, want 4", gotRA)
+			}
+			if gotRB := (w[0] >> 11) & 0x1f; gotRB != 5 {
+				t.Errorf("rB field = %d, want 5", gotRB)
+			}
+		})
+	}
+}
+
+func TestAssemblePairedSingles(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		xo   uint32
+	}{
+		{"ps_add", "ps_add f1, f2, f3", 21},
+		{"ps_sub", "ps_sub f1, f2, f3", 20},
+		{"ps_neg", "ps_neg f1, f2", 40},
+		{"ps_mr", "ps_mr f1, f2", 72},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := assemble(t, tt.src)
+			if gotOpcode := w[0] >> 26; gotOpcode != 4 {
+				t.Errorf("opcode = %d, want 4", gotOpcode)
+			}
+			if gotXO := (w[0] >> 1) & 0x3ff; gotXO != tt.xo {
+				t.Errorf("XO = %d, want %d", gotXO, tt.xo)
+			}
+			if gotFRT := (w[0] >> 21) & 0x1f; gotFRT != 1 {
+				t.Errorf("frT field = %d, want 1", gotFRT)
+			}
+			if gotFRA := (w[0] >> 16) & 0x1f; gotFRA != 2 {
+				t.Errorf("frA field = %d, want 2", gotFRA)
+			}
+		})
+	}
+}
+
+func TestAssemblePsMul(t *testing.T) {
+	// ps_mul is A-form (frT, frA, frC): frC sits at bits 6-10 rather than
+	// the frB slot (bits 11-15) the other paired-single ops use, and the
+	// sub-opcode is only 5 bits (bits 1-5).
+	w := assemble(t, "ps_mul f1, f2, f3")
+	if gotOpcode := w[0] >> 26; gotOpcode != 4 {
+		t.Errorf("opcode = %d, want 4", gotOpcode)
+	}
+	if gotSubOp := (w[0] >> 1) & 0x1f; gotSubOp != 25 {
+		t.Errorf("sub-opcode = %d, want 25", gotSubOp)
+	}
+	if gotFRC := (w[0] >> 6) & 0x1f; gotFRC != 3 {
+		t.Errorf("frC field = %d, want 3", gotFRC)
+	}
+	if gotFRT := (w[0] >> 21) & 0x1f; gotFRT != 1 {
+		t.Errorf("frT field = %d, want 1", gotFRT)
+	}
+	if gotFRA := (w[0] >> 16) & 0x1f; gotFRA != 2 {
+		t.Errorf("frA field = %d, want 2", gotFRA)
+	}
+}
+
+func TestAssemblePsqLoadStore(t *testing.T) {
+	w := assemble(t, "psq_l f1, 0x10(r3)")
+	if gotOpcode := w[0] >> 26; gotOpcode != 56 {
+		t.Errorf("opcode = %d, want 56", gotOpcode)
+	}
+	if gotFRT := (w[0] >> 21) & 0x1f; gotFRT != 1 {
+		t.Errorf("frT field = %d, want 1", gotFRT)
+	}
+	if gotRA := (w[0] >> 16) & 0x1f; gotRA != 3 {
+		t.Errorf("rA field = %d, want 3", gotRA)
+	}
+	if gotDisp := w[0] & 0xfff; gotDisp != 0x10 {
+		t.Errorf("displacement = %#x, want 0x10", gotDisp)
+	}
+}
+
+func TestAssembleRcSuffixSetsRcBit(t *testing.T) {
+	plain := assemble(t, "add r3, r4, r5")
+	rc := assemble(t, "add. r3, r4, r5")
+	if plain[0]&1 != 0 {
+		t.Errorf("add (no dot) Rc bit = %d, want 0", plain[0]&1)
+	}
+	if rc[0]&1 != 1 {
+		t.Errorf("add. Rc bit = %d, want 1", rc[0]&1)
+	}
+	if plain[0]&^1 != rc[0]&^1 {
+		t.Errorf("add. should differ from add only in the Rc bit: %#08x vs %#08x", plain[0], rc[0])
+	}
+}
+
+func TestAssembleArithmeticImmediateDForm(t *testing.T) {
+	w := assemble(t, "addi r3, r4, 0x10")
+	if gotOpcode := w[0] >> 26; gotOpcode != 14 {
+		t.Errorf("opcode = %d, want 14", gotOpcode)
+	}
+	if gotRT := (w[0] >> 21) & 0x1f; gotRT != 3 {
+		t.Errorf("rT field = %d, want 3", gotRT)
+	}
+	if gotRA := (w[0] >> 16) & 0x1f; gotRA != 4 {
+		t.Errorf("rA field = %d, want 4", gotRA)
+	}
+	if gotSimm := w[0] & 0xffff; gotSimm != 0x10 {
+		t.Errorf("SIMM = %#x, want 0x10", gotSimm)
+	}
+}
+
+func TestAssembleLogicalImmDFormFieldOrderIsSwapped(t *testing.T) {
+	// ori rA, rS, UIMM puts the source in bits 21-25 (rT's slot) and the
+	// destination in bits 16-20 (rA's slot) - the opposite of addi's
+	// rT, rA, SIMM layout.
+	w := assemble(t, "ori r3, r4, 0x10")
+	if gotSource := (w[0] >> 21) & 0x1f; gotSource != 4 {
+		t.Errorf("source field (bits 21-25) = %d, want 4", gotSource)
+	}
+	if gotDest := (w[0] >> 16) & 0x1f; gotDest != 3 {
+		t.Errorf("dest field (bits 16-20) = %d, want 3", gotDest)
+	}
+}
+
+func TestAssembleCmpwiCmplwi(t *testing.T) {
+	for _, tt := range []struct {
+		name   string
+		src    string
+		opcode uint32
+	}{
+		{"cmpwi", "cmpwi r3, 5", 11},
+		{"cmplwi", "cmplwi r3, 5", 10},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			w := assemble(t, tt.src)
+			if gotOpcode := w[0] >> 26; gotOpcode != tt.opcode {
+				t.Errorf("opcode = %d, want %d", gotOpcode, tt.opcode)
+			}
+			if gotRA := (w[0] >> 16) & 0x1f; gotRA != 3 {
+				t.Errorf("rA field = %d, want 3", gotRA)
+			}
+			if gotImm := w[0] & 0xffff; gotImm != 5 {
+				t.Errorf("imm = %d, want 5", gotImm)
+			}
+		})
+	}
+}
+
+func TestAssembleRegisterAliases(t *testing.T) {
+	// sp/rtoc are EABI aliases for r1/r2.
+	w := assemble(t, "addi sp, rtoc, 0x0")
+	if gotRT := (w[0] >> 21) & 0x1f; gotRT != 1 {
+		t.Errorf("sp field = %d, want 1", gotRT)
+	}
+	if gotRA := (w[0] >> 16) & 0x1f; gotRA != 2 {
+		t.Errorf("rtoc field = %d, want 2", gotRA)
+	}
+}
+
+func TestAssembleMrPseudoOp(t *testing.T) {
+	// mr rT, rS expands to `or rT, rS, rS`.
+	w := assemble(t, "mr r3, r4")
+	if gotOpcode := w[0] >> 26; gotOpcode != 31 {
+		t.Errorf("opcode = %d, want 31", gotOpcode)
+	}
+	if gotXO := (w[0] >> 1) & 0x3ff; gotXO != 444 {
+		t.Errorf("XO = %d, want 444 (or)", gotXO)
+	}
+}
@@ -0,0 +1,604 @@
+// Package ppcas is a small, self-contained assembler for the subset of the
+// PowerPC (Broadway/Gekko) instruction set commonly used in Wii/GameCube
+// homebrew injections. It accepts the same source flavor gecko's users
+// already write for powerpc-eabi-as (labels, .set, .long, .byte, .align,
+// register names via -mregnames, simple arithmetic in operands) and emits
+// raw big-endian machine code with no ELF wrapper, so callers don't need to
+// round-trip through a temp file and an external toolchain.
+//
+// It does not aim to cover the entire PPC ISA, only the subset documented
+// in the Gekko/Broadway manuals that shows up in typical Gecko code
+// injections: integer arithmetic/logic, loads/stores, branches, condition
+// register ops, mfspr/mtspr, and Gekko paired-singles.
+package ppcas
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Error reports a failure at a specific source line, matching the style of
+// diagnostics powerpc-eabi-as prints (so error messages surfaced to users
+// stay familiar).
+type Error struct {
+	Line int
+	Msg  string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("line %d: %s", e.Line, e.Msg)
+}
+
+// AssembleFile reads and assembles the asm source at path, returning raw
+// big-endian machine code.
+func AssembleFile(path string) ([]byte, error) {
+	result, err := AssembleFileWithOptions(path, Options{})
+	if err != nil {
+		return nil, err
+	}
+	return result.Code, nil
+}
+
+// Options controls how AssembleWithOptions lays out and resolves a source
+// file: BaseAddress matters when the code will live somewhere other than
+// offset 0 (e.g. a shared library region linked at a fixed game address),
+// Predefined seeds the symbol table before layout runs, so source can
+// reference external symbols (game addresses, cross-file globals) by name
+// instead of hardcoding hex, and Dir resolves `.include "file"` directives'
+// relative paths. AssembleFileWithOptions fills Dir in from the source
+// file's own directory when left blank.
+type Options struct {
+	BaseAddress uint32
+	Predefined  map[string]uint32
+	Dir         string
+}
+
+// Result is what AssembleWithOptions returns: the raw machine code, the
+// fully resolved symbol table, and the subset of names declared via
+// .global/.globl, for callers that need to export labels to other files.
+type Result struct {
+	Code    []byte
+	Symbols map[string]uint32
+	Globals []string
+}
+
+// AssembleFileWithOptions is AssembleFile with Options.
+func AssembleFileWithOptions(path string, opts Options) (Result, error) {
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		return Result{}, fmt.Errorf("failed to read asm file: %w", err)
+	}
+
+	if opts.Dir == "" {
+		opts.Dir = filepath.Dir(path)
+	}
+
+	return AssembleWithOptions(string(contents), opts)
+}
+
+// statement is a single parsed line of source: an optional label, an
+// optional directive or instruction, and the operands that follow it.
+type statement struct {
+	line   int
+	label  string
+	op     string
+	args   []string
+	addr   uint32
+	size   uint32
+	global bool
+}
+
+// Assemble assembles PPC source into raw big-endian machine code.
+func Assemble(src string) ([]byte, error) {
+	result, err := AssembleWithOptions(src, Options{})
+	if err != nil {
+		return nil, err
+	}
+	return result.Code, nil
+}
+
+// AssembleWithOptions is Assemble with Options.
+func AssembleWithOptions(src string, opts Options) (Result, error) {
+	src, err := expandIncludes(src, opts.Dir, map[string]bool{})
+	if err != nil {
+		return Result{}, err
+	}
+
+	stmts, err := parse(src)
+	if err != nil {
+		return Result{}, err
+	}
+
+	symbols := map[string]uint32{}
+	for name, value := range opts.Predefined {
+		symbols[name] = value
+	}
+
+	if err := layout(stmts, symbols, opts.BaseAddress); err != nil {
+		return Result{}, err
+	}
+
+	code, err := emit(stmts, symbols, opts.BaseAddress)
+	if err != nil {
+		return Result{}, err
+	}
+
+	var globals []string
+	for i := range stmts {
+		if stmts[i].global {
+			globals = append(globals, stmts[i].args...)
+		}
+	}
+
+	return Result{Code: code, Symbols: symbols, Globals: globals}, nil
+}
+
+// expandIncludes inlines every `.include "file"`/`.include <file>` directive
+// in src, resolving relative paths against dir (the including file's own
+// directory, matching powerpc-eabi-as), so a native-assembled project can
+// split shared declarations into headers the same way a devkitPro project
+// already does. seen is shared across the recursion: it guards against
+// include cycles and, like gecko's own cache.go:resolveIncludes, keeps a
+// diamond-included file from being inlined (and its labels redefined) more
+// than once.
+func expandIncludes(src, dir string, seen map[string]bool) (string, error) {
+	var out strings.Builder
+
+	for _, line := range strings.Split(src, "\n") {
+		name := includeTarget(line)
+		if name == "" {
+			out.WriteString(line)
+			out.WriteString("\n")
+			continue
+		}
+
+		incPath := filepath.Join(dir, name)
+		if seen[incPath] {
+			continue
+		}
+		seen[incPath] = true
+
+		contents, err := os.ReadFile(incPath)
+		if err != nil {
+			return "", fmt.Errorf("failed to read included file %q: %w", incPath, err)
+		}
+
+		expanded, err := expandIncludes(string(contents), filepath.Dir(incPath), seen)
+		if err != nil {
+			return "", err
+		}
+		out.WriteString(expanded)
+		out.WriteString("\n")
+	}
+
+	return out.String(), nil
+}
+
+// includeTarget returns the quoted/angle-bracketed filename of a `.include`
+// directive line, or "" if line isn't one.
+func includeTarget(line string) string {
+	line = strings.TrimSpace(stripComment(line))
+	if !strings.HasPrefix(line, ".include") {
+		return ""
+	}
+
+	rest := strings.TrimSpace(line[len(".include"):])
+	if len(rest) < 2 {
+		return ""
+	}
+
+	open, close := byte('"'), byte('"')
+	if rest[0] == '<' {
+		open, close = '<', '>'
+	}
+	if rest[0] != open {
+		return ""
+	}
+
+	end := strings.IndexByte(rest[1:], close)
+	if end < 0 {
+		return ""
+	}
+
+	return rest[1 : end+1]
+}
+
+func parse(src string) ([]statement, error) {
+	var stmts []statement
+
+	for lineNo, rawLine := range strings.Split(src, "\n") {
+		line := stripComment(rawLine)
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+
+		st := statement{line: lineNo + 1}
+
+		// A label is `name:` at the start of the line; it may share a line
+		// with an instruction or directive.
+		if idx := strings.Index(line, ":"); idx >= 0 && isLabelName(line[:idx]) {
+			st.label = line[:idx]
+			line = strings.TrimSpace(line[idx+1:])
+		}
+
+		if line == "" {
+			stmts = append(stmts, st)
+			continue
+		}
+
+		fields := strings.SplitN(line, " ", 2)
+		st.op = strings.ToLower(fields[0])
+		if len(fields) > 1 {
+			st.args = splitArgs(fields[1])
+		}
+
+		if st.op == ".global" || st.op == ".globl" {
+			st.global = true
+		}
+
+		stmts = append(stmts, st)
+	}
+
+	return stmts, nil
+}
+
+func stripComment(line string) string {
+	if idx := strings.Index(line, "#"); idx >= 0 {
+		line = line[:idx]
+	}
+	if idx := strings.Index(line, "//"); idx >= 0 {
+		line = line[:idx]
+	}
+	return line
+}
+
+func isLabelName(s string) bool {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return false
+	}
+	for i, r := range s {
+		if r == '_' || r == '.' || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') {
+			continue
+		}
+		if i > 0 && r >= '0' && r <= '9' {
+			continue
+		}
+		return false
+	}
+	return true
+}
+
+func splitArgs(s string) []string {
+	parts := strings.Split(s, ",")
+	args := make([]string, 0, len(parts))
+	for _, p := range parts {
+		p = strings.TrimSpace(p)
+		if p != "" {
+			args = append(args, p)
+		}
+	}
+	return args
+}
+
+// layout is the assembler's first pass: it walks every statement assigning
+// addresses so forward label references (branches, .long symbol, etc.) can
+// be resolved in the second pass regardless of definition order.
+func layout(stmts []statement, symbols map[string]uint32, base uint32) error {
+	addr := base
+
+	for i := range stmts {
+		st := &stmts[i]
+		if st.label != "" {
+			if _, exists := symbols[st.label]; exists {
+				return &Error{st.line, fmt.Sprintf("label %q redefined", st.label)}
+			}
+			symbols[st.label] = addr
+		}
+
+		size, err := statementSize(st, addr, symbols)
+		if err != nil {
+			return err
+		}
+		st.addr = addr
+		st.size = size
+		addr += size
+	}
+
+	return nil
+}
+
+func statementSize(st *statement, addr uint32, symbols map[string]uint32) (uint32, error) {
+	switch st.op {
+	case "":
+		return 0, nil
+	case ".set":
+		if len(st.args) != 2 {
+			return 0, &Error{st.line, ".set requires a name and a value"}
+		}
+		val, err := evalExpr(st.args[1], symbols)
+		if err != nil {
+			return 0, &Error{st.line, err.Error()}
+		}
+		symbols[st.args[0]] = val
+		return 0, nil
+	case ".global", ".globl", ".text", ".data", ".align":
+		if st.op == ".align" {
+			return alignPadding(st, addr, symbols)
+		}
+		return 0, nil
+	case ".long", ".word", ".int":
+		return uint32(len(st.args)) * 4, nil
+	case ".short", ".hword":
+		return uint32(len(st.args)) * 2, nil
+	case ".byte":
+		return uint32(len(st.args)), nil
+	case ".space", ".skip":
+		if len(st.args) != 1 {
+			return 0, &Error{st.line, st.op + " requires exactly one argument"}
+		}
+		n, err := evalExpr(st.args[0], symbols)
+		if err != nil {
+			return 0, &Error{st.line, err.Error()}
+		}
+		return n, nil
+	default:
+		return 4, nil
+	}
+}
+
+func alignPadding(st *statement, addr uint32, symbols map[string]uint32) (uint32, error) {
+	if len(st.args) != 1 {
+		return 0, &Error{st.line, ".align requires exactly one argument"}
+	}
+	bits, err := evalExpr(st.args[0], symbols)
+	if err != nil {
+		return 0, &Error{st.line, err.Error()}
+	}
+	boundary := uint32(1) << bits
+	if rem := addr % boundary; rem != 0 {
+		return boundary - rem, nil
+	}
+	return 0, nil
+}
+
+func emit(stmts []statement, symbols map[string]uint32, base uint32) ([]byte, error) {
+	var buf bytes.Buffer
+
+	for i := range stmts {
+		st := &stmts[i]
+		if st.op == "" || st.op == ".set" || st.op == ".global" || st.op == ".globl" ||
+			st.op == ".text" || st.op == ".data" {
+			continue
+		}
+
+		if int(st.addr-base) != buf.Len() && st.op != ".align" {
+			// Shouldn't happen given layout() tracked the same sizes, but
+			// keep the invariant explicit rather than silently drifting.
+			return nil, &Error{st.line, "internal assembler error: address/size mismatch"}
+		}
+
+		switch st.op {
+		case ".align":
+			buf.Write(make([]byte, st.size))
+		case ".long", ".word", ".int":
+			for _, a := range st.args {
+				v, err := evalExpr(a, symbols)
+				if err != nil {
+					return nil, &Error{st.line, err.Error()}
+				}
+				buf.Write([]byte{byte(v >> 24), byte(v >> 16), byte(v >> 8), byte(v)})
+			}
+		case ".short", ".hword":
+			for _, a := range st.args {
+				v, err := evalExpr(a, symbols)
+				if err != nil {
+					return nil, &Error{st.line, err.Error()}
+				}
+				buf.Write([]byte{byte(v >> 8), byte(v)})
+			}
+		case ".byte":
+			for _, a := range st.args {
+				v, err := evalExpr(a, symbols)
+				if err != nil {
+					return nil, &Error{st.line, err.Error()}
+				}
+				buf.WriteByte(byte(v))
+			}
+		case ".space", ".skip":
+			buf.Write(make([]byte, st.size))
+		default:
+			word, err := encodeInstruction(st, symbols)
+			if err != nil {
+				return nil, err
+			}
+			buf.Write([]byte{byte(word >> 24), byte(word >> 16), byte(word >> 8), byte(word)})
+		}
+	}
+
+	return buf.Bytes(), nil
+}
+
+// relocSuffixes are the @ha/@h/@l operand suffixes powerpc-eabi-as accepts
+// on the high/low halves of a lis+addi (or lis+ori) pair, letting e.g.
+// `lis r3, my_symbol@ha` / `addi r3, r3, my_symbol@l` split a 32-bit
+// symbol address across the two instructions instead of hardcoding it.
+var relocSuffixes = []string{"@ha", "@h", "@l"}
+
+// evalExpr evaluates the small subset of C-like arithmetic expressions
+// powerpc-eabi-as allows in operands: +, -, *, /, parens, hex (0x...) and
+// decimal literals, previously defined symbols, and an optional trailing
+// @ha/@h/@l relocation suffix.
+func evalExpr(expr string, symbols map[string]uint32) (uint32, error) {
+	expr = strings.TrimSpace(expr)
+
+	for _, reloc := range relocSuffixes {
+		if strings.HasSuffix(expr, reloc) {
+			value, err := evalExpr(expr[:len(expr)-len(reloc)], symbols)
+			if err != nil {
+				return 0, err
+			}
+			return applyReloc(reloc, value), nil
+		}
+	}
+
+	p := &exprParser{s: expr, symbols: symbols}
+	val, err := p.parseExpr()
+	if err != nil {
+		return 0, err
+	}
+	p.skipSpace()
+	if p.pos != len(p.s) {
+		return 0, fmt.Errorf("unexpected trailing characters in expression %q", expr)
+	}
+	return val, nil
+}
+
+type exprParser struct {
+	s       string
+	pos     int
+	symbols map[string]uint32
+}
+
+func (p *exprParser) skipSpace() {
+	for p.pos < len(p.s) && p.s[p.pos] == ' ' {
+		p.pos++
+	}
+}
+
+func (p *exprParser) parseExpr() (uint32, error) {
+	val, err := p.parseTerm()
+	if err != nil {
+		return 0, err
+	}
+	for {
+		p.skipSpace()
+		if p.pos >= len(p.s) {
+			return val, nil
+		}
+		op := p.s[p.pos]
+		if op != '+' && op != '-' {
+			return val, nil
+		}
+		p.pos++
+		rhs, err := p.parseTerm()
+		if err != nil {
+			return 0, err
+		}
+		if op == '+' {
+			val += rhs
+		} else {
+			val -= rhs
+		}
+	}
+}
+
+func (p *exprParser) parseTerm() (uint32, error) {
+	val, err := p.parseFactor()
+	if err != nil {
+		return 0, err
+	}
+	for {
+		p.skipSpace()
+		if p.pos >= len(p.s) {
+			return val, nil
+		}
+		op := p.s[p.pos]
+		if op != '*' && op != '/' {
+			return val, nil
+		}
+		p.pos++
+		rhs, err := p.parseFactor()
+		if err != nil {
+			return 0, err
+		}
+		if op == '*' {
+			val *= rhs
+		} else {
+			if rhs == 0 {
+				return 0, fmt.Errorf("division by zero")
+			}
+			val /= rhs
+		}
+	}
+}
+
+func (p *exprParser) parseFactor() (uint32, error) {
+	p.skipSpace()
+	if p.pos >= len(p.s) {
+		return 0, fmt.Errorf("unexpected end of expression")
+	}
+
+	if p.s[p.pos] == '(' {
+		p.pos++
+		val, err := p.parseExpr()
+		if err != nil {
+			return 0, err
+		}
+		p.skipSpace()
+		if p.pos >= len(p.s) || p.s[p.pos] != ')' {
+			return 0, fmt.Errorf("missing closing paren")
+		}
+		p.pos++
+		return val, nil
+	}
+
+	if p.s[p.pos] == '-' {
+		p.pos++
+		val, err := p.parseFactor()
+		if err != nil {
+			return 0, err
+		}
+		return uint32(-int64(val)), nil
+	}
+
+	start := p.pos
+	for p.pos < len(p.s) && !strings.ContainsRune("+-*/() ", rune(p.s[p.pos])) {
+		p.pos++
+	}
+	tok := p.s[start:p.pos]
+	if tok == "" {
+		return 0, fmt.Errorf("invalid expression %q", p.s)
+	}
+
+	if v, ok := p.symbols[tok]; ok {
+		return v, nil
+	}
+
+	literal := tok
+	base := 10
+	if strings.HasPrefix(literal, "0x") || strings.HasPrefix(literal, "0X") {
+		base = 16
+		literal = literal[2:]
+	}
+	v, err := strconv.ParseUint(literal, base, 32)
+	if err != nil {
+		return 0, fmt.Errorf("undefined symbol or invalid literal %q", tok)
+	}
+	return uint32(v), nil
+}
+
+// applyReloc extracts the half of value the given @ha/@h/@l suffix
+// selects. @ha additionally rounds up the high half when the low half
+// will be sign-extended by the instruction it feeds (e.g. addi), so that
+// `lis r3, sym@ha` / `addi r3, r3, sym@l` reconstructs the exact address.
+func applyReloc(reloc string, value uint32) uint32 {
+	switch reloc {
+	case "@l":
+		return value & 0xFFFF
+	case "@h":
+		return (value >> 16) & 0xFFFF
+	case "@ha":
+		high := (value >> 16) & 0xFFFF
+		if value&0x8000 != 0 {
+			high = (high + 1) & 0xFFFF
+		}
+		return high
+	}
+	return value
+}
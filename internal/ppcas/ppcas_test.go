@@ -0,0 +1,248 @@
+package ppcas_test
+
+import (
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hosaka-corp/gecko/internal/ppcas"
+)
+
+// words decodes code into big-endian 32-bit instruction words, failing the
+// test if code isn't a whole number of words.
+func words(t *testing.T, code []byte) []uint32 {
+	t.Helper()
+	if len(code)%4 != 0 {
+		t.Fatalf("code length %d is not a multiple of 4", len(code))
+	}
+	out := make([]uint32, len(code)/4)
+	for i := range out {
+		out[i] = binary.BigEndian.Uint32(code[i*4 : i*4+4])
+	}
+	return out
+}
+
+func assemble(t *testing.T, src string) []uint32 {
+	t.Helper()
+	code, err := ppcas.Assemble(src)
+	if err != nil {
+		t.Fatalf("failed to assemble %q: %v", src, err)
+	}
+	return words(t, code)
+}
+
+func TestAssembleSetAndExpr(t *testing.T) {
+	got := assemble(t, ".set FOO, 0x10\n.set BAR, FOO * 2 + 1\n.long BAR")
+	if want := uint32(0x21); got[0] != want {
+		t.Errorf("got %#x, want %#x", got[0], want)
+	}
+}
+
+func TestAssembleExprParens(t *testing.T) {
+	got := assemble(t, ".long (1 + 2) * 3")
+	if want := uint32(9); got[0] != want {
+		t.Errorf("got %#x, want %#x", got[0], want)
+	}
+}
+
+func TestAssembleDivisionByZero(t *testing.T) {
+	if _, err := ppcas.Assemble(".long 1 / 0"); err == nil {
+		t.Fatal("expected division by zero to fail assembly")
+	}
+}
+
+func TestAssembleAlign(t *testing.T) {
+	code, err := ppcas.Assemble(".byte 1\n.align 2\n.long 0xAABBCCDD")
+	if err != nil {
+		t.Fatalf("failed to assemble: %v", err)
+	}
+	// .byte 1 leaves addr=1, .align 2 pads up to the next 4-byte boundary
+	// (3 bytes), so the .long should land at offset 4.
+	if len(code) != 8 {
+		t.Fatalf("len(code) = %d, want 8 (1 padding byte + 3 align bytes + 4 data bytes)", len(code))
+	}
+	if got := binary.BigEndian.Uint32(code[4:8]); got != 0xAABBCCDD {
+		t.Errorf("got %#x, want 0xAABBCCDD", got)
+	}
+}
+
+func TestAssembleAlignAlreadyAligned(t *testing.T) {
+	code, err := ppcas.Assemble(".align 2\n.long 0x1")
+	if err != nil {
+		t.Fatalf("failed to assemble: %v", err)
+	}
+	if len(code) != 4 {
+		t.Fatalf("len(code) = %d, want 4 (.align at an already-aligned address should pad nothing)", len(code))
+	}
+}
+
+func TestAssembleSpace(t *testing.T) {
+	code, err := ppcas.Assemble(".space 6\n.byte 0xFF")
+	if err != nil {
+		t.Fatalf("failed to assemble: %v", err)
+	}
+	if len(code) != 7 {
+		t.Fatalf("len(code) = %d, want 7", len(code))
+	}
+	for i := 0; i < 6; i++ {
+		if code[i] != 0 {
+			t.Errorf("code[%d] = %#x, want 0 (.space should zero-fill)", i, code[i])
+		}
+	}
+	if code[6] != 0xFF {
+		t.Errorf("code[6] = %#x, want 0xFF", code[6])
+	}
+}
+
+func TestAssembleShortAndByte(t *testing.T) {
+	code, err := ppcas.Assemble(".short 0x1234, 0x5678\n.byte 1, 2, 3")
+	if err != nil {
+		t.Fatalf("failed to assemble: %v", err)
+	}
+	want := []byte{0x12, 0x34, 0x56, 0x78, 1, 2, 3}
+	if len(code) != len(want) {
+		t.Fatalf("len(code) = %d, want %d", len(code), len(want))
+	}
+	for i := range want {
+		if code[i] != want[i] {
+			t.Errorf("code[%d] = %#x, want %#x", i, code[i], want[i])
+		}
+	}
+}
+
+func TestAssembleLabelRedefinedFails(t *testing.T) {
+	_, err := ppcas.Assemble("foo:\n.long 1\nfoo:\n.long 2")
+	if err == nil {
+		t.Fatal("expected a redefined label to fail assembly")
+	}
+}
+
+func TestAssembleForwardLabelReference(t *testing.T) {
+	// `b forward` must resolve even though forward's address isn't known
+	// until layout has walked the rest of the statements.
+	code, err := ppcas.Assemble("b forward\nnop\nforward:\nblr")
+	if err != nil {
+		t.Fatalf("failed to assemble: %v", err)
+	}
+	w := words(t, code)
+	if got, want := w[0]&0x3fffffc, uint32(8); got != want {
+		t.Errorf("branch displacement = %#x, want %#x (skip over the nop to forward)", got, want)
+	}
+}
+
+func TestAssembleAtHaRoundsUpOnCarry(t *testing.T) {
+	// 0x80010000's low half (0x0000) doesn't set the sign bit, so @ha should
+	// round down to the plain high half, while an address whose low half
+	// does set bit 15 (e.g. 0x80018123) needs @ha to round up so that
+	// lis+addi (where addi sign-extends @l) reconstructs the full address.
+	noCarry := assemble(t, ".set my_sym, 0x80010000\n.long my_sym@ha")
+	if noCarry[0] != 0x8001 {
+		t.Errorf("@ha with no carry = %#x, want 0x8001", noCarry[0])
+	}
+
+	withCarry := assemble(t, ".set my_sym, 0x80018123\n.long my_sym@ha")
+	if withCarry[0] != 0x8002 {
+		t.Errorf("@ha with carry = %#x, want 0x8002 (low half 0x8123 should round the high half up)", withCarry[0])
+	}
+
+	low := assemble(t, ".set my_sym, 0x80018123\n.long my_sym@l")
+	if low[0] != 0x8123 {
+		t.Errorf("@l = %#x, want 0x8123", low[0])
+	}
+}
+
+func TestAssembleUnknownMnemonicFails(t *testing.T) {
+	if _, err := ppcas.Assemble("frobnicate r3, r4"); err == nil {
+		t.Fatal("expected an unknown mnemonic to fail assembly")
+	}
+}
+
+func TestExpandIncludesResolvesRelativeToDir(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "consts.inc"), []byte(".set FOO, 0x42\n"), 0644); err != nil {
+		t.Fatalf("failed to write fixture: %v", err)
+	}
+
+	result, err := ppcas.AssembleWithOptions(".include \"consts.inc\"\n.long FOO", ppcas.Options{Dir: dir})
+	if err != nil {
+		t.Fatalf("failed to assemble: %v", err)
+	}
+	if got := binary.BigEndian.Uint32(result.Code); got != 0x42 {
+		t.Errorf("got %#x, want 0x42", got)
+	}
+}
+
+func TestExpandIncludesDiamondOnlyInlinedOnce(t *testing.T) {
+	// a.asm and b.asm both .include shared.inc; if expandIncludes didn't
+	// guard against re-inlining an already-seen file, shared.inc's `val:`
+	// label would be defined twice and assembly would fail with a
+	// redefined-label error instead of succeeding.
+	dir := t.TempDir()
+	files := map[string]string{
+		"shared.inc": "val:\n.long 1\n",
+		"a.inc":      ".include \"shared.inc\"\n",
+		"b.inc":      ".include \"shared.inc\"\n",
+	}
+	for name, contents := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(contents), 0644); err != nil {
+			t.Fatalf("failed to write fixture %s: %v", name, err)
+		}
+	}
+
+	_, err := ppcas.AssembleWithOptions(".include \"a.inc\"\n.include \"b.inc\"", ppcas.Options{Dir: dir})
+	if err != nil {
+		t.Fatalf("diamond include should assemble cleanly, got: %v", err)
+	}
+}
+
+func TestExpandIncludesMissingFileFails(t *testing.T) {
+	_, err := ppcas.AssembleWithOptions(".include \"does_not_exist.inc\"", ppcas.Options{Dir: t.TempDir()})
+	if err == nil {
+		t.Fatal("expected a missing .include target to fail assembly")
+	}
+}
+
+func TestAssembleFileWithOptionsDefaultsDirFromPath(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "consts.inc"), []byte(".set FOO, 0x7\n"), 0644); err != nil {
+		t.Fatalf("failed to write fixture: %v", err)
+	}
+	mainPath := filepath.Join(dir, "main.asm")
+	if err := os.WriteFile(mainPath, []byte(".include \"consts.inc\"\n.long FOO"), 0644); err != nil {
+		t.Fatalf("failed to write fixture: %v", err)
+	}
+
+	result, err := ppcas.AssembleFileWithOptions(mainPath, ppcas.Options{})
+	if err != nil {
+		t.Fatalf("failed to assemble: %v", err)
+	}
+	if got := binary.BigEndian.Uint32(result.Code); got != 0x7 {
+		t.Errorf("got %#x, want 0x7", got)
+	}
+}
+
+func TestAssembleGlobalExportsToResult(t *testing.T) {
+	result, err := ppcas.AssembleWithOptions(".global my_func\nmy_func:\nblr", ppcas.Options{BaseAddress: 0x80001000})
+	if err != nil {
+		t.Fatalf("failed to assemble: %v", err)
+	}
+	if len(result.Globals) != 1 || result.Globals[0] != "my_func" {
+		t.Fatalf("Globals = %v, want [my_func]", result.Globals)
+	}
+	if result.Symbols["my_func"] != 0x80001000 {
+		t.Errorf("Symbols[my_func] = %#x, want 0x80001000", result.Symbols["my_func"])
+	}
+}
+
+func TestAssemblePredefinedSymbols(t *testing.T) {
+	result, err := ppcas.AssembleWithOptions(".long known_symbol", ppcas.Options{
+		Predefined: map[string]uint32{"known_symbol": 0x1234},
+	})
+	if err != nil {
+		t.Fatalf("failed to assemble: %v", err)
+	}
+	if got := binary.BigEndian.Uint32(result.Code); got != 0x1234 {
+		t.Errorf("got %#x, want 0x1234", got)
+	}
+}
@@ -0,0 +1,56 @@
+package ppcas
+
+import "strconv"
+
+// gpr resolves a general purpose register operand, accepting both the
+// plain r0..r31 form and the EABI aliases -mregnames exposes (sp, rtoc).
+func gpr(tok string) (uint32, bool) {
+	switch tok {
+	case "sp":
+		return 1, true
+	case "rtoc":
+		return 2, true
+	}
+	return numberedReg(tok, "r")
+}
+
+// fpr resolves a floating point register operand (f0..f31), which also
+// back Gekko's paired-single instructions.
+func fpr(tok string) (uint32, bool) {
+	return numberedReg(tok, "f")
+}
+
+// crf resolves a condition register field operand (cr0..cr7).
+func crf(tok string) (uint32, bool) {
+	return numberedReg(tok, "cr")
+}
+
+func numberedReg(tok, prefix string) (uint32, bool) {
+	if len(tok) <= len(prefix) || tok[:len(prefix)] != prefix {
+		return 0, false
+	}
+	n, err := strconv.Atoi(tok[len(prefix):])
+	if err != nil || n < 0 || n > 31 {
+		return 0, false
+	}
+	return uint32(n), true
+}
+
+// spr maps the special purpose register names used by mfspr/mtspr to their
+// numeric encoding, split into the 5-bit halves the instruction uses.
+func spr(tok string) (uint32, bool) {
+	var n uint32
+	switch tok {
+	case "xer":
+		n = 1
+	case "lr":
+		n = 8
+	case "ctr":
+		n = 9
+	default:
+		return 0, false
+	}
+	// The instruction encodes spr as two 5-bit halves swapped relative to
+	// its natural bit order.
+	return (n&0x1f)<<5 | (n >> 5), true
+}
@@ -0,0 +1,477 @@
+package ppcsim
+
+import "fmt"
+
+// execute decodes word (the instruction at the current PC) and carries out
+// its effect, advancing c.PC itself so branches can override the default
+// fall-through of PC+4. Only the mnemonics internal/ppcas can emit are
+// decoded; anything else reports an unsupported-opcode error rather than
+// silently doing nothing.
+func (c *CPU) execute(word uint32) error {
+	opcode := word >> 26
+	nextPC := c.PC + 4
+
+	switch opcode {
+	case 7: // mulli
+		rt, ra, simm := dFormFields(word)
+		c.GPR[rt] = c.gprOrZero(ra) * uint32(int32(signExtend16(simm)))
+	case 8: // subfic
+		rt, ra, simm := dFormFields(word)
+		c.GPR[rt] = uint32(int32(signExtend16(simm)) - int32(c.gprOrZero(ra)))
+	case 10: // cmplwi
+		c.execCmpImm(word, false)
+	case 11: // cmpwi
+		c.execCmpImm(word, true)
+	case 12: // addic
+		rt, ra, simm := dFormFields(word)
+		c.GPR[rt] = c.gprOrZero(ra) + uint32(int32(signExtend16(simm)))
+	case 13: // addic.
+		rt, ra, simm := dFormFields(word)
+		result := c.gprOrZero(ra) + uint32(int32(signExtend16(simm)))
+		c.GPR[rt] = result
+		c.setCR0(result)
+	case 14: // addi / li
+		rt, ra, simm := dFormFields(word)
+		c.GPR[rt] = c.gprOrZero(ra) + uint32(int32(signExtend16(simm)))
+	case 15: // addis / lis
+		rt, ra, simm := dFormFields(word)
+		c.GPR[rt] = c.gprOrZero(ra) + simm<<16
+	case 16: // conditional branch
+		if target, taken := c.condBranchTarget(word); taken {
+			nextPC = target
+		}
+	case 18: // b/bl/ba/bla
+		li := signExtend26(word & 0x03fffffc)
+		target := uint32(int32(c.PC) + int32(li))
+		if word&2 != 0 { // AA
+			target = word & 0x03fffffc
+		}
+		if word&1 != 0 { // LK
+			c.LR = c.PC + 4
+		}
+		nextPC = target
+	case 19: // bclr/bcctr (blr, blrl, bctr, bctrl)
+		ext := (word >> 1) & 0x3ff
+		switch ext {
+		case 16: // bclr
+			target := c.LR
+			if word&1 != 0 {
+				c.LR = c.PC + 4
+			}
+			nextPC = target
+		case 528: // bcctr
+			target := c.CTR
+			if word&1 != 0 {
+				c.LR = c.PC + 4
+			}
+			nextPC = target
+		default:
+			return fmt.Errorf("unsupported branch extended opcode %d at 0x%08X", ext, c.PC)
+		}
+	case 24: // ori
+		c.execLogicalImm(word, func(rs, uimm uint32) uint32 { return rs | uimm }, false)
+	case 25: // oris
+		c.execLogicalImm(word, func(rs, uimm uint32) uint32 { return rs | uimm }, true)
+	case 26: // xori
+		c.execLogicalImm(word, func(rs, uimm uint32) uint32 { return rs ^ uimm }, false)
+	case 27: // xoris
+		c.execLogicalImm(word, func(rs, uimm uint32) uint32 { return rs ^ uimm }, true)
+	case 28: // andi.
+		c.execLogicalImmRc(word, func(rs, uimm uint32) uint32 { return rs & uimm }, false)
+	case 29: // andis.
+		c.execLogicalImmRc(word, func(rs, uimm uint32) uint32 { return rs & uimm }, true)
+	case 31:
+		if err := c.executeExtended(word); err != nil {
+			return err
+		}
+	case 32: // lwz
+		rt, ea := c.dFormMemEA(word)
+		c.GPR[rt] = c.Mem.WordAt(ea)
+	case 33: // lwzu
+		rt, ea := c.dFormMemEA(word)
+		c.GPR[rt] = c.Mem.WordAt(ea)
+		c.GPR[(word>>16)&0x1f] = ea
+	case 34: // lbz
+		rt, ea := c.dFormMemEA(word)
+		c.GPR[rt] = uint32(c.Mem.ByteAt(ea))
+	case 35: // lbzu
+		rt, ea := c.dFormMemEA(word)
+		c.GPR[rt] = uint32(c.Mem.ByteAt(ea))
+		c.GPR[(word>>16)&0x1f] = ea
+	case 36: // stw
+		rt, ea := c.dFormMemEA(word)
+		c.Mem.SetWordAt(ea, c.GPR[rt])
+	case 37: // stwu
+		rt, ea := c.dFormMemEA(word)
+		c.Mem.SetWordAt(ea, c.GPR[rt])
+		c.GPR[(word>>16)&0x1f] = ea
+	case 38: // stb
+		rt, ea := c.dFormMemEA(word)
+		c.Mem.SetByteAt(ea, byte(c.GPR[rt]))
+	case 39: // stbu
+		rt, ea := c.dFormMemEA(word)
+		c.Mem.SetByteAt(ea, byte(c.GPR[rt]))
+		c.GPR[(word>>16)&0x1f] = ea
+	case 40: // lhz
+		rt, ea := c.dFormMemEA(word)
+		c.GPR[rt] = uint32(c.Mem.HalfAt(ea))
+	case 41: // lhzu
+		rt, ea := c.dFormMemEA(word)
+		c.GPR[rt] = uint32(c.Mem.HalfAt(ea))
+		c.GPR[(word>>16)&0x1f] = ea
+	case 42: // lha
+		rt, ea := c.dFormMemEA(word)
+		c.GPR[rt] = uint32(int32(int16(c.Mem.HalfAt(ea))))
+	case 43: // lhau
+		rt, ea := c.dFormMemEA(word)
+		c.GPR[rt] = uint32(int32(int16(c.Mem.HalfAt(ea))))
+		c.GPR[(word>>16)&0x1f] = ea
+	case 44: // sth
+		rt, ea := c.dFormMemEA(word)
+		c.Mem.SetHalfAt(ea, uint16(c.GPR[rt]))
+	case 45: // sthu
+		rt, ea := c.dFormMemEA(word)
+		c.Mem.SetHalfAt(ea, uint16(c.GPR[rt]))
+		c.GPR[(word>>16)&0x1f] = ea
+	default:
+		return fmt.Errorf("unsupported opcode %d at 0x%08X", opcode, c.PC)
+	}
+
+	c.PC = nextPC
+	return nil
+}
+
+// dFormFields pulls the three fields a D-form "dest, src, SIMM" instruction
+// (addi/addic/addis/mulli/subfic/...) shares: field1 is the destination,
+// field2 the source register, and the low 16 bits the immediate.
+func dFormFields(word uint32) (rt, ra, imm uint32) {
+	return (word >> 21) & 0x1f, (word >> 16) & 0x1f, word & 0xffff
+}
+
+// gprOrZero reads GPR n, except n==0 always reads as 0 - the PPC D-form/
+// X-form convention where RA==0 means "no base register" rather than GPR0.
+func (c *CPU) gprOrZero(n uint32) uint32 {
+	if n == 0 {
+		return 0
+	}
+	return c.GPR[n]
+}
+
+// execLogicalImm executes the ori/oris/xori/xoris family: unlike the
+// arithmetic D-form group, their bit layout puts the source register in
+// field1 and the destination in field2 (RA = op(RS, UIMM)), and they never
+// touch CR.
+func (c *CPU) execLogicalImm(word uint32, op func(rs, uimm uint32) uint32, shifted bool) {
+	rs, ra, uimm := dFormFields(word)
+	if shifted {
+		uimm <<= 16
+	}
+	c.GPR[ra] = op(c.GPR[rs], uimm)
+}
+
+// execLogicalImmRc is execLogicalImm for the andi./andis. pair, which always
+// update CR0 from the result.
+func (c *CPU) execLogicalImmRc(word uint32, op func(rs, uimm uint32) uint32, shifted bool) {
+	rs, ra, uimm := dFormFields(word)
+	if shifted {
+		uimm <<= 16
+	}
+	result := op(c.GPR[rs], uimm)
+	c.GPR[ra] = result
+	c.setCR0(result)
+}
+
+// dFormMemEA decodes a D-form load/store's `rT, d(rA)` operands into the
+// register field and the effective address.
+func (c *CPU) dFormMemEA(word uint32) (rt, ea uint32) {
+	rt = (word >> 21) & 0x1f
+	ra := (word >> 16) & 0x1f
+	disp := int32(signExtend16(word & 0xffff))
+	return rt, uint32(int32(c.gprOrZero(ra)) + disp)
+}
+
+// execCmpImm executes cmpwi (signed) or cmplwi (unsigned) against the crfD
+// selected by bits 21-23 (always cr0 for anything internal/ppcas emits).
+func (c *CPU) execCmpImm(word uint32, signed bool) {
+	crfD := (word >> 23) & 0x7
+	ra := (word >> 16) & 0x1f
+	imm := word & 0xffff
+
+	a := c.gprOrZero(ra)
+	var lt, gt bool
+	if signed {
+		b := int32(signExtend16(imm))
+		lt, gt = int32(a) < b, int32(a) > b
+	} else {
+		lt, gt = a < imm, a > imm
+	}
+
+	c.CR[crfD] = CRField{LT: lt, GT: gt, EQ: !lt && !gt}
+}
+
+// condBranch decodes BO/BI/BD/AA/LK and reports the branch's target address
+// and whether it's taken, for the beq/bne/blt/bgt/ble/bge family condBranch
+// in internal/ppcas encodes (BO is always 12 "branch if true" or 4 "branch
+// if false", so bdnz-style CTR decrement branches aren't supported).
+func (c *CPU) condBranchTarget(word uint32) (target uint32, taken bool) {
+	bo := (word >> 21) & 0x1f
+	bi := (word >> 16) & 0x1f
+	bd := signExtend16(word & 0xfffc)
+
+	crIdx := bi / 4
+	bit := crBit(c.CR[crIdx], bi%4)
+
+	switch bo {
+	case 12:
+		taken = bit
+	case 4:
+		taken = !bit
+	default:
+		taken = true
+	}
+
+	if word&2 != 0 { // AA
+		target = word & 0xfffc
+	} else {
+		target = uint32(int32(c.PC) + int32(bd))
+	}
+	return target, taken
+}
+
+// crBit reads one of a CR field's four bits, in the same LT(0)/GT(1)/EQ(2)/
+// SO(3) order condBranch's BI offsets use.
+func crBit(f CRField, bit uint32) bool {
+	switch bit {
+	case 0:
+		return f.LT
+	case 1:
+		return f.GT
+	case 2:
+		return f.EQ
+	case 3:
+		return f.SO
+	}
+	return false
+}
+
+// setCR0 updates cr0 from a signed arithmetic/logical result, as every Rc=1
+// instruction and andi./andis. do.
+func (c *CPU) setCR0(result uint32) {
+	c.CR[0] = CRField{LT: int32(result) < 0, GT: int32(result) > 0, EQ: result == 0}
+}
+
+// sprNumber maps mtspr/mfspr's packed 10-bit field back to the SPR number:
+// internal/ppcas's spr() swaps the number's two 5-bit halves to match the
+// instruction's split encoding, and that swap is its own inverse.
+func sprNumber(field uint32) uint32 {
+	return (field&0x1f)<<5 | (field >> 5)
+}
+
+const (
+	sprXER = 1
+	sprLR  = 8
+	sprCTR = 9
+)
+
+// executeExtended handles every opcode-31 instruction: indexed loads/
+// stores, XO-form arithmetic, X-form logical/shift ops, neg/extsb/extsh,
+// and the mtspr/mfspr family, all distinguished by the 10-bit extended
+// opcode in bits 1-10 (see internal/ppcas's xForm/xoForm/sprForm, which this
+// mirrors in reverse).
+func (c *CPU) executeExtended(word uint32) error {
+	ext := (word >> 1) & 0x3ff
+	rc := word & 1
+
+	rt := (word >> 21) & 0x1f // aka rS for store/logical-source fields
+	ra := (word >> 16) & 0x1f
+	rb := (word >> 11) & 0x1f
+
+	switch ext {
+	// Indexed loads/stores.
+	case 23: // lwzx
+		c.GPR[rt] = c.Mem.WordAt(c.gprOrZero(ra) + c.GPR[rb])
+	case 87: // lbzx
+		c.GPR[rt] = uint32(c.Mem.ByteAt(c.gprOrZero(ra) + c.GPR[rb]))
+	case 279: // lhzx
+		c.GPR[rt] = uint32(c.Mem.HalfAt(c.gprOrZero(ra) + c.GPR[rb]))
+	case 343: // lhax
+		c.GPR[rt] = uint32(int32(int16(c.Mem.HalfAt(c.gprOrZero(ra) + c.GPR[rb]))))
+	case 151: // stwx
+		c.Mem.SetWordAt(c.gprOrZero(ra)+c.GPR[rb], c.GPR[rt])
+	case 215: // stbx
+		c.Mem.SetByteAt(c.gprOrZero(ra)+c.GPR[rb], byte(c.GPR[rt]))
+	case 407: // sthx
+		c.Mem.SetHalfAt(c.gprOrZero(ra)+c.GPR[rb], uint16(c.GPR[rt]))
+
+	// Arithmetic XO-form (rT, rA, rB), with carry tracked through XER.
+	case 266: // add
+		c.setXO(rt, c.GPR[ra]+c.GPR[rb], rc)
+	case 10: // addc
+		result, ca := addWithCarry(c.GPR[ra], c.GPR[rb], 0)
+		c.setCA(ca)
+		c.setXO(rt, result, rc)
+	case 138: // adde
+		result, ca := addWithCarry(c.GPR[ra], c.GPR[rb], c.caIn())
+		c.setCA(ca)
+		c.setXO(rt, result, rc)
+	case 40: // subf
+		c.setXO(rt, c.GPR[rb]-c.GPR[ra], rc)
+	case 8: // subfc
+		result, ca := addWithCarry(^c.GPR[ra], c.GPR[rb], 1)
+		c.setCA(ca)
+		c.setXO(rt, result, rc)
+	case 136: // subfe
+		result, ca := addWithCarry(^c.GPR[ra], c.GPR[rb], c.caIn())
+		c.setCA(ca)
+		c.setXO(rt, result, rc)
+	case 235: // mullw
+		c.setXO(rt, uint32(int32(c.GPR[ra])*int32(c.GPR[rb])), rc)
+	case 491: // divw
+		if c.GPR[rb] == 0 {
+			return fmt.Errorf("divw by zero at 0x%08X", c.PC)
+		}
+		c.setXO(rt, uint32(int32(c.GPR[ra])/int32(c.GPR[rb])), rc)
+
+	// Logical/shift X-form (rA, rS, rB): field1 is the source, field2 the
+	// destination.
+	case 28: // and
+		c.setLogical(ra, c.GPR[rt]&c.GPR[rb], rc)
+	case 444: // or
+		c.setLogical(ra, c.GPR[rt]|c.GPR[rb], rc)
+	case 316: // xor
+		c.setLogical(ra, c.GPR[rt]^c.GPR[rb], rc)
+	case 124: // nor
+		c.setLogical(ra, ^(c.GPR[rt] | c.GPR[rb]), rc)
+	case 476: // nand
+		c.setLogical(ra, ^(c.GPR[rt] & c.GPR[rb]), rc)
+	case 60: // andc
+		c.setLogical(ra, c.GPR[rt]&^c.GPR[rb], rc)
+	case 412: // orc
+		c.setLogical(ra, c.GPR[rt]|^c.GPR[rb], rc)
+	case 284: // eqv
+		c.setLogical(ra, ^(c.GPR[rt] ^ c.GPR[rb]), rc)
+	case 24: // slw
+		c.setLogical(ra, shiftLeft(c.GPR[rt], c.GPR[rb]), rc)
+	case 536: // srw
+		c.setLogical(ra, shiftRightLogical(c.GPR[rt], c.GPR[rb]), rc)
+	case 792: // sraw
+		c.setLogical(ra, shiftRightArith(c.GPR[rt], c.GPR[rb]), rc)
+
+	// Unary X-form (rT, rA).
+	case 104: // neg
+		c.setXO(rt, ^c.GPR[ra]+1, rc)
+	case 954: // extsb
+		c.setXO(rt, uint32(int32(int8(c.GPR[ra]))), rc)
+	case 922: // extsh
+		c.setXO(rt, uint32(int32(int16(c.GPR[ra]))), rc)
+
+	// mtspr/mfspr and their mtlr/mflr/mtctr/mfctr aliases.
+	case 467: // mtspr
+		c.setSpr(sprNumber((word>>11)&0x3ff), c.GPR[rt])
+	case 339: // mfspr
+		c.GPR[rt] = c.getSpr(sprNumber((word >> 11) & 0x3ff))
+
+	default:
+		return fmt.Errorf("unsupported extended opcode %d at 0x%08X", ext, c.PC)
+	}
+
+	return nil
+}
+
+func (c *CPU) setXO(rt, result, rc uint32) {
+	c.GPR[rt] = result
+	if rc != 0 {
+		c.setCR0(result)
+	}
+}
+
+func (c *CPU) setLogical(ra, result, rc uint32) {
+	c.GPR[ra] = result
+	if rc != 0 {
+		c.setCR0(result)
+	}
+}
+
+func (c *CPU) setSpr(n, v uint32) {
+	switch n {
+	case sprLR:
+		c.LR = v
+	case sprCTR:
+		c.CTR = v
+	case sprXER:
+		c.XER = v
+	}
+}
+
+func (c *CPU) getSpr(n uint32) uint32 {
+	switch n {
+	case sprLR:
+		return c.LR
+	case sprCTR:
+		return c.CTR
+	case sprXER:
+		return c.XER
+	}
+	return 0
+}
+
+// caIn/setCA read and write XER's carry bit (bit 29, the standard PPC XER
+// layout), the only XER bit addc/adde/subfc/subfe need.
+const xerCA = 1 << 29
+
+func (c *CPU) caIn() uint32 {
+	if c.XER&xerCA != 0 {
+		return 1
+	}
+	return 0
+}
+
+func (c *CPU) setCA(carry uint32) {
+	if carry != 0 {
+		c.XER |= xerCA
+	} else {
+		c.XER &^= xerCA
+	}
+}
+
+// addWithCarry adds a+b+carryIn as a 33-bit sum, returning the 32-bit result
+// and the carry out - shared by add/sub's plain and carry-propagating forms.
+func addWithCarry(a, b, carryIn uint32) (result, carryOut uint32) {
+	sum := uint64(a) + uint64(b) + uint64(carryIn)
+	return uint32(sum), uint32(sum >> 32)
+}
+
+func shiftLeft(v, by uint32) uint32 {
+	if by&0x3f >= 32 {
+		return 0
+	}
+	return v << (by & 0x3f)
+}
+
+func shiftRightLogical(v, by uint32) uint32 {
+	if by&0x3f >= 32 {
+		return 0
+	}
+	return v >> (by & 0x3f)
+}
+
+func shiftRightArith(v, by uint32) uint32 {
+	shift := by & 0x3f
+	if shift >= 32 {
+		if int32(v) < 0 {
+			return 0xffffffff
+		}
+		return 0
+	}
+	return uint32(int32(v) >> shift)
+}
+
+func signExtend16(v uint32) int32 {
+	return int32(int16(v))
+}
+
+func signExtend26(v uint32) int32 {
+	// v is already masked to bits 2-25 (bit 25 is the sign bit); shift up
+	// to the top of a 32-bit word and back down to sign-extend.
+	return int32(v<<6) >> 6
+}
@@ -0,0 +1,340 @@
+package ppcsim_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/hosaka-corp/gecko/internal/ppcas"
+	"github.com/hosaka-corp/gecko/internal/ppcsim"
+)
+
+// run assembles src at base, single-steps it to completion (Run stops the
+// moment PC falls off the end, so a bare sequence with no trailing blr is
+// enough) and returns the CPU for the test to inspect.
+func run(t *testing.T, src string, seed func(cpu *ppcsim.CPU)) *ppcsim.CPU {
+	t.Helper()
+
+	const base = 0x80003000
+	code, err := ppcas.AssembleWithOptions(src, ppcas.Options{BaseAddress: base})
+	if err != nil {
+		t.Fatalf("failed to assemble %q: %v", src, err)
+	}
+
+	cpu := ppcsim.NewCPU()
+	if seed != nil {
+		seed(cpu)
+	}
+	if _, err := ppcsim.Run(cpu, code.Code, base, 0); err != nil {
+		t.Fatalf("run failed for %q: %v", src, err)
+	}
+	return cpu
+}
+
+func TestStepArithmeticXOForm(t *testing.T) {
+	tests := []struct {
+		name string
+		asm  string
+		ra   uint32
+		rb   uint32
+		want uint32
+	}{
+		{"add", "add r3, r4, r5", 2, 3, 5},
+		{"subf", "subf r3, r4, r5", 2, 9, 7},
+		{"mullw", "mullw r3, r4, r5", 6, 7, 42},
+		{"divw", "divw r3, r4, r5", 42, 6, 7},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cpu := run(t, tt.asm, func(cpu *ppcsim.CPU) {
+				cpu.GPR[4] = tt.ra
+				cpu.GPR[5] = tt.rb
+			})
+			if cpu.GPR[3] != tt.want {
+				t.Errorf("r3 = %#x, want %#x", cpu.GPR[3], tt.want)
+			}
+		})
+	}
+}
+
+func TestStepDivwByZeroPanics(t *testing.T) {
+	const base = 0x80003000
+	code, err := ppcas.AssembleWithOptions("divw r3, r4, r5", ppcas.Options{BaseAddress: base})
+	if err != nil {
+		t.Fatalf("failed to assemble: %v", err)
+	}
+
+	cpu := ppcsim.NewCPU()
+	cpu.GPR[4] = 10
+	cpu.GPR[5] = 0
+	if _, err := ppcsim.Run(cpu, code.Code, base, 0); err == nil {
+		t.Fatal("expected divw by zero to return an error")
+	}
+}
+
+func TestStepCarryPropagatingArithmetic(t *testing.T) {
+	// addc sets XER's carry bit on unsigned overflow; adde folds it back in,
+	// so r3,r5 chained together model a 64-bit add of 0xFFFFFFFF + 1 + (5+0).
+	cpu := run(t, "addc r3, r4, r5\nadde r6, r7, r8", func(cpu *ppcsim.CPU) {
+		cpu.GPR[4] = 0xFFFFFFFF
+		cpu.GPR[5] = 1
+		cpu.GPR[7] = 5
+		cpu.GPR[8] = 0
+	})
+	if cpu.GPR[3] != 0 {
+		t.Errorf("addc result = %#x, want 0", cpu.GPR[3])
+	}
+	if cpu.GPR[6] != 6 {
+		t.Errorf("adde result = %#x, want 6 (carry from addc should have propagated)", cpu.GPR[6])
+	}
+}
+
+func TestStepRcFormUpdatesCR0(t *testing.T) {
+	cpu := run(t, "subf. r3, r4, r5", func(cpu *ppcsim.CPU) {
+		cpu.GPR[4] = 5
+		cpu.GPR[5] = 5
+	})
+	if !cpu.CR[0].EQ {
+		t.Errorf("cr0 = %+v, want EQ set for a zero result", cpu.CR[0])
+	}
+}
+
+func TestStepLogicalXForm(t *testing.T) {
+	tests := []struct {
+		name string
+		asm  string
+		want uint32
+	}{
+		{"and", "and r3, r4, r5", 0xF0 & 0x3C},
+		{"or", "or r3, r4, r5", 0xF0 | 0x3C},
+		{"xor", "xor r3, r4, r5", 0xF0 ^ 0x3C},
+		{"nor", "nor r3, r4, r5", ^uint32(0xF0 | 0x3C)},
+		{"nand", "nand r3, r4, r5", ^uint32(0xF0 & 0x3C)},
+		{"andc", "andc r3, r4, r5", 0xF0 &^ uint32(0x3C)},
+		{"orc", "orc r3, r4, r5", 0xF0 | ^uint32(0x3C)},
+		{"eqv", "eqv r3, r4, r5", ^(uint32(0xF0) ^ 0x3C)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Logical X-form's field order is rA, rS, rB: r4 is the source
+			// (rS), r3 the destination (rA).
+			cpu := run(t, tt.asm, func(cpu *ppcsim.CPU) {
+				cpu.GPR[4] = 0xF0
+				cpu.GPR[5] = 0x3C
+			})
+			if cpu.GPR[3] != tt.want {
+				t.Errorf("got %#x, want %#x", cpu.GPR[3], tt.want)
+			}
+		})
+	}
+}
+
+func TestStepShifts(t *testing.T) {
+	tests := []struct {
+		name string
+		asm  string
+		rs   uint32
+		by   uint32
+		want uint32
+	}{
+		{"slw", "slw r3, r4, r5", 0x1, 4, 0x10},
+		{"slw overflow", "slw r3, r4, r5", 0x1, 32, 0},
+		{"srw", "srw r3, r4, r5", 0x80000000, 4, 0x08000000},
+		{"srw overflow", "srw r3, r4, r5", 0xFFFFFFFF, 32, 0},
+		{"sraw negative", "sraw r3, r4, r5", 0xFFFFFFF0, 4, 0xFFFFFFFF},
+		{"sraw overflow negative", "sraw r3, r4, r5", 0x80000000, 40, 0xFFFFFFFF},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cpu := run(t, tt.asm, func(cpu *ppcsim.CPU) {
+				cpu.GPR[4] = tt.rs
+				cpu.GPR[5] = tt.by
+			})
+			if cpu.GPR[3] != tt.want {
+				t.Errorf("got %#x, want %#x", cpu.GPR[3], tt.want)
+			}
+		})
+	}
+}
+
+func TestStepUnaryXForm(t *testing.T) {
+	tests := []struct {
+		name string
+		asm  string
+		in   uint32
+		want uint32
+	}{
+		{"neg", "neg r3, r4", 5, 0xFFFFFFFB},
+		{"extsb positive", "extsb r3, r4", 0x7F, 0x7F},
+		{"extsb negative", "extsb r3, r4", 0xFF, 0xFFFFFFFF},
+		{"extsh positive", "extsh r3, r4", 0x7FFF, 0x7FFF},
+		{"extsh negative", "extsh r3, r4", 0xFFFF, 0xFFFFFFFF},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cpu := run(t, tt.asm, func(cpu *ppcsim.CPU) { cpu.GPR[4] = tt.in })
+			if cpu.GPR[3] != tt.want {
+				t.Errorf("got %#x, want %#x", cpu.GPR[3], tt.want)
+			}
+		})
+	}
+}
+
+func TestStepCmpAndConditionalBranch(t *testing.T) {
+	tests := []struct {
+		name   string
+		branch string
+		r3     uint32
+		taken  bool
+	}{
+		{"beq taken", "beq", 5, true},
+		{"beq not taken", "beq", 6, false},
+		{"bne taken", "bne", 6, true},
+		{"bne not taken", "bne", 5, false},
+		{"blt taken", "blt", 4, true},
+		{"blt not taken", "blt", 6, false},
+		{"bgt taken", "bgt", 6, true},
+		{"bgt not taken", "bgt", 4, false},
+		{"ble taken", "ble", 5, true},
+		{"bge taken", "bge", 5, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			src := strings.Join([]string{
+				"cmpwi r3, 5",
+				tt.branch + " taken_label",
+				"li r4, 0",
+				"b done",
+				"taken_label:",
+				"li r4, 1",
+				"done:",
+				"li r5, 7",
+			}, "\n")
+
+			cpu := run(t, src, func(cpu *ppcsim.CPU) { cpu.GPR[3] = tt.r3 })
+
+			wantR4 := uint32(0)
+			if tt.taken {
+				wantR4 = 1
+			}
+			if cpu.GPR[4] != wantR4 {
+				t.Errorf("r4 = %d, want %d (branch %s taken=%v)", cpu.GPR[4], wantR4, tt.branch, tt.taken)
+			}
+			if cpu.GPR[5] != 7 {
+				t.Errorf("r5 = %d, want 7 (fell through to the shared tail)", cpu.GPR[5])
+			}
+		})
+	}
+}
+
+func TestStepCmplwiUnsignedCompare(t *testing.T) {
+	// cmplwi treats the operands as unsigned, so 0xFFFFFFFF compares greater
+	// than 5 - unlike cmpwi, which would treat it as -1 and take the branch.
+	cpu := run(t, strings.Join([]string{
+		"cmplwi r3, 5",
+		"blt less",
+		"li r4, 1",
+		"b done",
+		"less:",
+		"li r4, 0",
+		"done:",
+	}, "\n"), func(cpu *ppcsim.CPU) { cpu.GPR[3] = 0xFFFFFFFF })
+
+	if cpu.GPR[4] != 1 {
+		t.Errorf("r4 = %d, want 1 (0xFFFFFFFF should compare as unsigned >5, not signed <5)", cpu.GPR[4])
+	}
+}
+
+func TestStepSprRoundTrip(t *testing.T) {
+	tests := []struct {
+		name string
+		asm  string
+	}{
+		{"lr", "mtlr r4\nmflr r3"},
+		{"ctr", "mtctr r4\nmfctr r3"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cpu := run(t, tt.asm, func(cpu *ppcsim.CPU) { cpu.GPR[4] = 0x80001234 })
+			if cpu.GPR[3] != 0x80001234 {
+				t.Errorf("round trip through %s = %#x, want 0x80001234", tt.name, cpu.GPR[3])
+			}
+		})
+	}
+}
+
+func TestStepIndexedLoadsAndStores(t *testing.T) {
+	cpu := run(t, strings.Join([]string{
+		"stwx r6, r4, r5",
+		"lwzx r3, r4, r5",
+		"lbzx r7, r4, r5",
+	}, "\n"), func(cpu *ppcsim.CPU) {
+		cpu.GPR[4] = 0x1000
+		cpu.GPR[5] = 0x10
+		cpu.GPR[6] = 0xCAFEBABE
+	})
+
+	if cpu.GPR[3] != 0xCAFEBABE {
+		t.Errorf("lwzx = %#x, want 0xCAFEBABE", cpu.GPR[3])
+	}
+	if cpu.GPR[7] != 0xCA {
+		t.Errorf("lbzx = %#x, want 0xCA (the stored word's top byte)", cpu.GPR[7])
+	}
+}
+
+func TestStepDFormLoadsAndStores(t *testing.T) {
+	cpu := run(t, strings.Join([]string{
+		"stw r5, 0x10(r4)",
+		"lwz r3, 0x10(r4)",
+		"lhz r6, 0x10(r4)",
+		"lbz r7, 0x12(r4)",
+	}, "\n"), func(cpu *ppcsim.CPU) {
+		cpu.GPR[4] = 0x1000
+		cpu.GPR[5] = 0x12345678
+	})
+
+	if cpu.GPR[3] != 0x12345678 {
+		t.Errorf("lwz = %#x, want 0x12345678", cpu.GPR[3])
+	}
+	if cpu.GPR[6] != 0x1234 {
+		t.Errorf("lhz = %#x, want 0x1234 (the stored word's top half)", cpu.GPR[6])
+	}
+	if cpu.GPR[7] != 0x56 {
+		t.Errorf("lbz = %#x, want 0x56", cpu.GPR[7])
+	}
+}
+
+func TestStepAddiAndis(t *testing.T) {
+	cpu := run(t, strings.Join([]string{
+		"li r3, 0x1",
+		"ori r3, r3, 0x20",
+		"oris r3, r3, 0x1",
+		"andi. r4, r3, 0x20",
+	}, "\n"), nil)
+
+	if cpu.GPR[3] != 0x00010021 {
+		t.Errorf("r3 = %#x, want 0x00010021", cpu.GPR[3])
+	}
+	if cpu.GPR[4] != 0x20 {
+		t.Errorf("andi. result = %#x, want 0x20", cpu.GPR[4])
+	}
+	if !cpu.CR[0].GT {
+		t.Error("andi. should have set cr0.GT for a nonzero result")
+	}
+}
+
+func TestStepUnsupportedOpcodeErrors(t *testing.T) {
+	cpu := ppcsim.NewCPU()
+	// 0xFC000000 decodes to opcode 63, which internal/ppcas never emits and
+	// internal/ppcsim deliberately doesn't support.
+	cpu.Mem.SetWordAt(0x80003000, 0xFC000000)
+	cpu.PC = 0x80003000
+	if err := cpu.Step(); err == nil {
+		t.Fatal("expected Step to report an error for an unsupported opcode")
+	}
+}
@@ -0,0 +1,120 @@
+// Package ppcsim is a small interpreter for the same PowerPC (Broadway/
+// Gekko) instruction subset internal/ppcas assembles. It exists so `gecko
+// test` can single-step a compiled injection against a fake register/memory
+// state and assert the result, without round-tripping through Dolphin.
+//
+// Run models the Gecko C2 convention: a real codehandler copies the
+// injected block into its own codelist RAM and, once it finishes (falls off
+// the end, or hits a blr back to a return address outside the block),
+// itself performs the branch back to the original instruction. Run doesn't
+// simulate the codehandler, so it just stops the moment PC leaves the
+// block's address range, whether that happens by falling through or by a
+// branch - that's the natural boundary of what an injection can assert
+// about itself.
+package ppcsim
+
+import "fmt"
+
+// CRField holds the four condition bits a compare or Rc-updating
+// instruction sets for one CR field (cr0..cr7).
+type CRField struct {
+	LT, GT, EQ, SO bool
+}
+
+// CPU is one Broadway/Gekko register file plus the memory it operates on.
+type CPU struct {
+	GPR [32]uint32
+	LR  uint32
+	CTR uint32
+	XER uint32
+	CR  [8]CRField
+	PC  uint32
+	Mem *Memory
+}
+
+// NewCPU returns a CPU with zeroed registers and fresh memory, ready for a
+// caller to seed with initial state before Run.
+func NewCPU() *CPU {
+	return &CPU{Mem: NewMemory()}
+}
+
+// Memory is sparse, byte-addressed big-endian memory: only the addresses a
+// test actually touches are ever allocated, so a test can seed/assert a
+// handful of words without having to model the whole address space.
+type Memory struct {
+	bytes map[uint32]byte
+}
+
+// NewMemory returns an empty Memory.
+func NewMemory() *Memory {
+	return &Memory{bytes: map[uint32]byte{}}
+}
+
+// ByteAt and SetByteAt aren't named ReadByte/WriteByte because that pair
+// collides with the io.ByteReader/io.ByteWriter method signatures that `go
+// vet`'s stdmethods check expects.
+func (m *Memory) ByteAt(addr uint32) byte {
+	return m.bytes[addr]
+}
+
+func (m *Memory) SetByteAt(addr uint32, v byte) {
+	m.bytes[addr] = v
+}
+
+func (m *Memory) HalfAt(addr uint32) uint16 {
+	return uint16(m.ByteAt(addr))<<8 | uint16(m.ByteAt(addr+1))
+}
+
+func (m *Memory) SetHalfAt(addr uint32, v uint16) {
+	m.SetByteAt(addr, byte(v>>8))
+	m.SetByteAt(addr+1, byte(v))
+}
+
+func (m *Memory) WordAt(addr uint32) uint32 {
+	return uint32(m.HalfAt(addr))<<16 | uint32(m.HalfAt(addr+2))
+}
+
+func (m *Memory) SetWordAt(addr uint32, v uint32) {
+	m.SetHalfAt(addr, uint16(v>>16))
+	m.SetHalfAt(addr+2, uint16(v))
+}
+
+// defaultMaxSteps bounds how many instructions Run single-steps before
+// giving up, so a test whose injection branches on itself (a bug the test
+// is presumably trying to catch) reports an error instead of hanging.
+const defaultMaxSteps = 100000
+
+// Run loads code into cpu.Mem at base, sets cpu.PC to base, and single-steps
+// until PC leaves [base, base+len(code)) or maxSteps (defaultMaxSteps if 0)
+// is exceeded. It returns the number of instructions executed.
+func Run(cpu *CPU, code []byte, base uint32, maxSteps uint32) (uint32, error) {
+	for i, b := range code {
+		cpu.Mem.SetByteAt(base+uint32(i), b)
+	}
+	cpu.PC = base
+
+	if maxSteps == 0 {
+		maxSteps = defaultMaxSteps
+	}
+
+	end := base + uint32(len(code))
+	var steps uint32
+	for cpu.PC >= base && cpu.PC < end {
+		if steps >= maxSteps {
+			return steps, fmt.Errorf("exceeded %d steps without leaving the injection (infinite loop?)", maxSteps)
+		}
+		if err := cpu.Step(); err != nil {
+			return steps, err
+		}
+		steps++
+	}
+
+	return steps, nil
+}
+
+// Step decodes and executes the single instruction at cpu.PC, advancing PC
+// (to the next sequential instruction, or to a taken branch's target).
+func (c *CPU) Step() error {
+	word := c.Mem.WordAt(c.PC)
+	return c.execute(word)
+}
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/hosaka-corp/gecko/internal/ppcas"
+)
+
+// LibraryConfig places every .asm file in SourceFolder into one shared
+// "library" region at a fixed address, so a .global label declared in one
+// of them can be called by name (bl my_helper, lis r3, my_helper@ha) from
+// any other Build source in the project instead of every injection
+// needing its own copy of the helper.
+type LibraryConfig struct {
+	Address      string
+	SourceFolder string
+}
+
+// linkerSymbols holds every symbol available to `bl name`/`name@ha` style
+// references across the whole project: auto-discovered symbols.txt,
+// codes.json's Symbols section, and any .global labels the library region
+// exports. compileNative/compileExternal seed the assembler with this map
+// so individual Build sources don't need to hardcode game addresses.
+var linkerSymbols = map[string]uint32{}
+
+// defaultLibraryAddress is where the library region lands when codes.json
+// doesn't pin one down with Library.Address: a block of RAM past the
+// usual Gecko codehandler/codelist region that's free in practice on both
+// Melee and Brawl.
+const defaultLibraryAddress = 0x80001800
+
+// resolveLinkerSymbols populates linkerSymbols from symbols.txt,
+// config.Symbols, and (if configured) the library region's exported
+// globals, and returns the library's generated output lines (nil if no
+// Library is configured). Must run before any Build source is compiled.
+func resolveLinkerSymbols(config Config) []string {
+	linkerSymbols = map[string]uint32{}
+
+	if contents, err := ioutil.ReadFile("symbols.txt"); err == nil {
+		for name, value := range parseSymbolsFile(contents) {
+			linkerSymbols[name] = value
+		}
+	}
+
+	for name, value := range config.Symbols {
+		linkerSymbols[name] = parseSymbolAddress(name, value)
+	}
+
+	if config.Library == nil {
+		return nil
+	}
+
+	return buildLibraryRegion(*config.Library)
+}
+
+// parseSymbolsFile reads symbols.txt's `name = 0xADDR` lines, one per
+// line, with blank lines and #-comments ignored.
+func parseSymbolsFile(contents []byte) map[string]uint32 {
+	symbols := map[string]uint32{}
+
+	scanner := bufio.NewScanner(strings.NewReader(string(contents)))
+	for scanner.Scan() {
+		line := stripSymbolComment(scanner.Text())
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+
+		parts := strings.SplitN(line, "=", 2)
+		if len(parts) != 2 {
+			log.Panicf("Malformed line in symbols.txt: %q\n", line)
+		}
+
+		name := strings.TrimSpace(parts[0])
+		symbols[name] = parseSymbolAddress(name, strings.TrimSpace(parts[1]))
+	}
+
+	return symbols
+}
+
+func stripSymbolComment(line string) string {
+	if idx := strings.Index(line, "#"); idx >= 0 {
+		return line[:idx]
+	}
+	return line
+}
+
+// addressToUint32 parses a Gecko code address field (optionally
+// "0x"-prefixed hex) into the game address it names.
+func addressToUint32(address string) uint32 {
+	addr, err := strconv.ParseUint(strings.TrimPrefix(address, "0x"), 16, 32)
+	if err != nil {
+		log.Panicf("Failed to parse address %q\n%s\n", address, err.Error())
+	}
+	return uint32(addr)
+}
+
+func parseSymbolAddress(name, value string) uint32 {
+	addr, err := strconv.ParseUint(strings.TrimPrefix(value, "0x"), 16, 32)
+	if err != nil {
+		log.Panicf("Failed to parse address %q for symbol %q\n%s\n", value, name, err.Error())
+	}
+	return uint32(addr)
+}
+
+// collectAsmFilesInFolder returns every .asm file directly inside folder,
+// sorted by name so callers get a deterministic file order.
+func collectAsmFilesInFolder(folder string) []string {
+	contents, err := ioutil.ReadDir(folder)
+	if err != nil {
+		log.Panicf("Failed to read directory.%s\n", err.Error())
+	}
+
+	var files []string
+	for _, file := range contents {
+		if filepath.Ext(file.Name()) == ".asm" {
+			files = append(files, filepath.Join(folder, file.Name()))
+		}
+	}
+	sort.Strings(files)
+
+	return files
+}
+
+// buildLibraryRegion assembles every .asm file in lib.SourceFolder as one
+// contiguous blob at its fixed address, adds every .global label it
+// exports to linkerSymbols, and returns the replaceCodeBlock lines that
+// write the region into the output.
+//
+// This always goes through the native assembler rather than compile()'s
+// native/external dispatch: exporting .global labels to linkerSymbols
+// relies on ppcas.Result.Globals, which compileExternal has no equivalent
+// of (it just shells out to the external as and hands back raw bytes), so
+// Library has no meaningful "external" mode to fall back to.
+func buildLibraryRegion(lib LibraryConfig) []string {
+	if assembler == "external" {
+		log.Panicf("Library is not supported with --assembler=external: exporting .global symbols to other Build sources requires the native assembler\n")
+	}
+
+	address := lib.Address
+	if address == "" {
+		address = fmt.Sprintf("0x%08X", defaultLibraryAddress)
+	}
+
+	var src strings.Builder
+	for _, file := range collectAsmFilesInFolder(lib.SourceFolder) {
+		contents, err := ioutil.ReadFile(file)
+		if err != nil {
+			log.Panicf("Failed to read library source file %s\n%s\n", file, err.Error())
+		}
+		src.Write(contents)
+		src.WriteString("\n")
+	}
+
+	result, err := ppcas.AssembleWithOptions(src.String(), ppcas.Options{
+		BaseAddress: parseSymbolAddress("library", address),
+		Predefined:  linkerSymbols,
+		Dir:         lib.SourceFolder,
+	})
+	if err != nil {
+		log.Panicf("Failed to assemble library region %s\n%s\n", lib.SourceFolder, err.Error())
+	}
+
+	for _, name := range result.Globals {
+		linkerSymbols[name] = result.Symbols[name]
+	}
+
+	lines := generateReplaceCodeBlockLines(address, result.Code, lib.SourceFolder)
+	lines[0] = addLineAnnotation(lines[0], "library")
+	return lines
+}
+
+// defsymArgs renders linkerSymbols as the -defsym name=0xvalue flags
+// compileExternal passes to powerpc-eabi-as/powerpc-gekko-as, the external
+// assembler's equivalent of compileNative's Options.Predefined.
+func defsymArgs() []string {
+	args := make([]string, 0, len(linkerSymbols)*2)
+	for name, value := range linkerSymbols {
+		args = append(args, "-defsym", fmt.Sprintf("%s=0x%X", name, value))
+	}
+	return args
+}
+
+// writeSymbolsMap emits a .map file listing every resolved linker symbol
+// and its final address, sorted by name, the way a real linker's map
+// output would.
+func writeSymbolsMap(outputFile string) {
+	names := make([]string, 0, len(linkerSymbols))
+	for name := range linkerSymbols {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	lines := make([]string, 0, len(names))
+	for _, name := range names {
+		lines = append(lines, fmt.Sprintf("%08X %s", linkerSymbols[name], name))
+	}
+
+	ioutil.WriteFile(outputFile, []byte(strings.Join(lines, "\n")+"\n"), 0644)
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestBuildOutputEntrySplitsAnnotations checks that buildOutputEntry pairs
+// each raw "code #annotation" line up into OutputEntry's parallel
+// Codes/Annotations fields, leaving Annotations empty where a line had no
+// inline comment.
+func TestBuildOutputEntrySplitsAnnotations(t *testing.T) {
+	header := OutputHeader{Name: "My Code", Authors: []string{"me"}}
+	entry := buildOutputEntry("code", header, []string{
+		"04800010 3860002A #testdata/injectfolder/a.asm",
+		"04800014 3860002A",
+	})
+
+	if entry.Kind != "code" || entry.Header.Name != "My Code" {
+		t.Fatalf("entry = %+v, want Kind %q and Header.Name %q", entry, "code", "My Code")
+	}
+
+	wantCodes := []string{"04800010 3860002A", "04800014 3860002A"}
+	wantAnnotations := []string{"testdata/injectfolder/a.asm", ""}
+
+	for i := range wantCodes {
+		if entry.Codes[i] != wantCodes[i] {
+			t.Errorf("Codes[%d] = %q, want %q", i, entry.Codes[i], wantCodes[i])
+		}
+		if entry.Annotations[i] != wantAnnotations[i] {
+			t.Errorf("Annotations[%d] = %q, want %q", i, entry.Annotations[i], wantAnnotations[i])
+		}
+	}
+}
+
+// writeOutputFile points the writer under test at a temp file and returns
+// its contents, so each test below only has to assert on the resulting
+// bytes.
+func writeOutputFile(t *testing.T, write func(outputFile string)) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "out")
+	write(path)
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read written output: %v", err)
+	}
+	return string(contents)
+}
+
+func sampleOutput() []OutputEntry {
+	return []OutputEntry{{
+		Kind:        "code",
+		Header:      OutputHeader{Name: "My Code", Authors: []string{"me"}, Description: []string{"does a thing"}},
+		Codes:       []string{"04800010 3860002A"},
+		Annotations: []string{""},
+	}}
+}
+
+// TestWriteTextOutput checks the plain $Name/*description/hex-line body
+// every other writer's header/code rendering is built on.
+func TestWriteTextOutput(t *testing.T) {
+	output = sampleOutput()
+	got := writeOutputFile(t, func(f string) { writeTextOutput(f) })
+
+	want := "$My Code [me]\n*does a thing\n04800010 3860002A\n"
+	if got != want {
+		t.Errorf("writeTextOutput wrote %q, want %q", got, want)
+	}
+}
+
+// TestWriteOcarinaOutput checks the Ocarina/GCTRM variant wraps the same
+// body in the "[Gecko Codes]" banner line those tools expect.
+func TestWriteOcarinaOutput(t *testing.T) {
+	output = sampleOutput()
+	got := writeOutputFile(t, func(f string) { writeOcarinaOutput(f) })
+
+	if !strings.HasPrefix(got, "[Gecko Codes]\n\n") {
+		t.Fatalf("writeOcarinaOutput = %q, want it to start with the [Gecko Codes] banner", got)
+	}
+	if !strings.Contains(got, "$My Code [me]\n*does a thing\n04800010 3860002A") {
+		t.Errorf("writeOcarinaOutput = %q, want it to still contain the usual header/code body", got)
+	}
+}
+
+// TestWriteIniOutput checks the Dolphin GameSettings ini format: a [Gecko]
+// section carrying the full body, followed by a [Gecko_Enabled] section
+// listing each code's name so Dolphin defaults it to enabled.
+func TestWriteIniOutput(t *testing.T) {
+	output = sampleOutput()
+	got := writeOutputFile(t, func(f string) { writeIniOutput(f) })
+
+	wantGeckoSection := "[Gecko]\n$My Code [me]\n*does a thing\n04800010 3860002A\n"
+	if !strings.Contains(got, wantGeckoSection) {
+		t.Errorf("writeIniOutput = %q, want it to contain %q", got, wantGeckoSection)
+	}
+
+	wantEnabledSection := "[Gecko_Enabled]\n$My Code"
+	if !strings.Contains(got, wantEnabledSection) {
+		t.Errorf("writeIniOutput = %q, want it to contain %q", got, wantEnabledSection)
+	}
+
+	if strings.Index(got, "[Gecko]") > strings.Index(got, "[Gecko_Enabled]") {
+		t.Errorf("writeIniOutput = %q, want [Gecko] before [Gecko_Enabled]", got)
+	}
+}
+
+// TestWriteXmlOutput checks the Nintendont/USB Loader GX cheat DB format:
+// one <cheat> element per code, its hex lines joined inside <codes>, and
+// its name/creator/comment fields escaped.
+func TestWriteXmlOutput(t *testing.T) {
+	output = []OutputEntry{{
+		Kind:        "code",
+		Header:      OutputHeader{Name: "A & B", Authors: []string{"me"}, Description: []string{"<desc>"}},
+		Codes:       []string{"04800010 3860002A", "04800014 3860002B"},
+		Annotations: []string{"", ""},
+	}}
+	got := writeOutputFile(t, func(f string) { writeXmlOutput(f) })
+
+	if !strings.Contains(got, "<name>A &amp; B</name>") {
+		t.Errorf("writeXmlOutput = %q, want an escaped <name> element", got)
+	}
+	if !strings.Contains(got, "<comment>&lt;desc&gt;</comment>") {
+		t.Errorf("writeXmlOutput = %q, want an escaped <comment> element", got)
+	}
+	wantCodes := "<codes>04800010 3860002A\n04800014 3860002B</codes>"
+	if !strings.Contains(got, wantCodes) {
+		t.Errorf("writeXmlOutput = %q, want it to contain %q", got, wantCodes)
+	}
+}
+
+// TestWriteGctOutput checks the raw .gct container: the 8 byte 00D0C0DE
+// magic header, each code line decoded from hex straight into the body,
+// and the F0000000...  terminator.
+func TestWriteGctOutput(t *testing.T) {
+	output = []OutputEntry{{
+		Kind:  "code",
+		Codes: []string{"04800010 3860002A"},
+	}}
+	path := filepath.Join(t.TempDir(), "out.gct")
+	writeGctOutput(path)
+
+	got, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read written output: %v", err)
+	}
+
+	want := []byte{
+		0x00, 0xD0, 0xC0, 0xDE, 0x00, 0xD0, 0xC0, 0xDE,
+		0x04, 0x80, 0x00, 0x10, 0x38, 0x60, 0x00, 0x2A,
+		0xF0, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+	}
+	if string(got) != string(want) {
+		t.Errorf("writeGctOutput wrote %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,286 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/hosaka-corp/gecko/internal/ppcas"
+	"github.com/hosaka-corp/gecko/internal/ppcsim"
+)
+
+// testFileSuffix is the extension `gecko test` discovers: *.geckotest.json,
+// so a test fixture can live right next to the .asm file it exercises
+// without being mistaken for codes.json or an InjectFolder source.
+const testFileSuffix = ".geckotest.json"
+
+// defaultTestBaseAddress anchors a test's injection when its file doesn't
+// set BaseAddress, matching defaultLibraryAddress's role for the library
+// region: any free block of RAM works since the test never touches the
+// surrounding game state.
+const defaultTestBaseAddress = 0x80001800
+
+// InjectionTest is one regression test for a compiled injection: Source is
+// assembled with the native backend (seeded with the project's
+// linkerSymbols, so it can reference the same names Build sources do), run
+// from Initial state to completion, and the result is checked against
+// Expect.
+type InjectionTest struct {
+	Name        string
+	Source      string
+	BaseAddress string
+	MaxSteps    uint32
+	Initial     InjectionState
+	Expect      InjectionState
+}
+
+// InjectionState is a register/memory snapshot, used both to seed an
+// InjectionTest's starting state and to describe the conditions it's
+// expected to leave behind. Registers accepts r0-r31, lr, ctr, and xer,
+// hex strings like "0x80000000"; Memory is keyed the same way by address
+// and holds one 32-bit word per entry.
+type InjectionState struct {
+	Registers map[string]string
+	Memory    map[string]string
+}
+
+// runGeckoTests implements `gecko test`: it discovers every *.geckotest.json
+// file under dir, runs each against the native ppcsim interpreter, and
+// prints a PASS/FAIL line per test. It returns false if any test failed.
+func runGeckoTests(dir string) bool {
+	files := collectTestFiles(dir)
+	if len(files) == 0 {
+		fmt.Printf("gecko test: no %s files found under %s\n", testFileSuffix, dir)
+		return true
+	}
+
+	allPassed := true
+	for _, file := range files {
+		test, err := readInjectionTestFile(file)
+		name := file
+		if err == nil && test.Name != "" {
+			name = test.Name
+		}
+
+		if err == nil {
+			err = RunInjectionTest(filepath.Dir(file), test)
+		}
+
+		if err != nil {
+			fmt.Printf("FAIL %s: %s\n", name, err.Error())
+			allPassed = false
+			continue
+		}
+		fmt.Printf("PASS %s\n", name)
+	}
+
+	return allPassed
+}
+
+// collectTestFiles returns every *.geckotest.json file under dir, sorted so
+// `gecko test` reports in a deterministic order.
+func collectTestFiles(dir string) []string {
+	var files []string
+	filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return nil
+		}
+		if strings.HasSuffix(path, testFileSuffix) {
+			files = append(files, path)
+		}
+		return nil
+	})
+	sort.Strings(files)
+	return files
+}
+
+func readInjectionTestFile(path string) (InjectionTest, error) {
+	contents, err := ioutil.ReadFile(path)
+	if err != nil {
+		return InjectionTest{}, err
+	}
+
+	var test InjectionTest
+	if err := json.Unmarshal(contents, &test); err != nil {
+		return InjectionTest{}, fmt.Errorf("invalid test file: %w", err)
+	}
+
+	return test, nil
+}
+
+// RunInjectionTest assembles test.Source (resolved relative to baseDir),
+// seeds a CPU with test.Initial, runs it through ppcsim.Run following the
+// C2 return-to-original-instruction convention (see internal/ppcsim), and
+// checks the result against test.Expect. It's exported so injection_test.go
+// can share it with the `gecko test` subcommand.
+func RunInjectionTest(baseDir string, test InjectionTest) error {
+	base := uint32(defaultTestBaseAddress)
+	if test.BaseAddress != "" {
+		addr, err := parseHexUint32(test.BaseAddress)
+		if err != nil {
+			return fmt.Errorf("baseAddress: %w", err)
+		}
+		base = addr
+	}
+
+	source := test.Source
+	if !filepath.IsAbs(source) {
+		source = filepath.Join(baseDir, source)
+	}
+
+	result, err := ppcas.AssembleFileWithOptions(source, ppcas.Options{
+		BaseAddress: base,
+		Predefined:  linkerSymbols,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to assemble %s: %w", source, err)
+	}
+
+	cpu := ppcsim.NewCPU()
+	if err := applyState(cpu, test.Initial); err != nil {
+		return fmt.Errorf("invalid initial state: %w", err)
+	}
+
+	if _, err := ppcsim.Run(cpu, result.Code, base, test.MaxSteps); err != nil {
+		return fmt.Errorf("run failed: %w", err)
+	}
+
+	return checkState(cpu, test.Expect)
+}
+
+// applyState seeds cpu's registers and memory from state, as parsed from an
+// InjectionTest's Initial section.
+func applyState(cpu *ppcsim.CPU, state InjectionState) error {
+	for name, value := range state.Registers {
+		v, err := parseHexUint32(value)
+		if err != nil {
+			return fmt.Errorf("register %s: %w", name, err)
+		}
+		if err := setRegister(cpu, name, v); err != nil {
+			return err
+		}
+	}
+
+	for addr, value := range state.Memory {
+		a, err := parseHexUint32(addr)
+		if err != nil {
+			return fmt.Errorf("memory address %s: %w", addr, err)
+		}
+		v, err := parseHexUint32(value)
+		if err != nil {
+			return fmt.Errorf("memory %s: %w", addr, err)
+		}
+		cpu.Mem.SetWordAt(a, v)
+	}
+
+	return nil
+}
+
+// checkState compares cpu against state (an InjectionTest's Expect
+// section), reporting the first mismatch found, registers before memory, in
+// sorted key order so a failure is always reported the same way.
+func checkState(cpu *ppcsim.CPU, state InjectionState) error {
+	for _, name := range sortedKeys(state.Registers) {
+		want, err := parseHexUint32(state.Registers[name])
+		if err != nil {
+			return fmt.Errorf("register %s: %w", name, err)
+		}
+		got, err := getRegister(cpu, name)
+		if err != nil {
+			return err
+		}
+		if got != want {
+			return fmt.Errorf("register %s = 0x%08X, want 0x%08X", name, got, want)
+		}
+	}
+
+	for _, addr := range sortedKeys(state.Memory) {
+		want, err := parseHexUint32(state.Memory[addr])
+		if err != nil {
+			return fmt.Errorf("memory %s: %w", addr, err)
+		}
+		a, err := parseHexUint32(addr)
+		if err != nil {
+			return fmt.Errorf("memory address %s: %w", addr, err)
+		}
+		if got := cpu.Mem.WordAt(a); got != want {
+			return fmt.Errorf("memory[%s] = 0x%08X, want 0x%08X", addr, got, want)
+		}
+	}
+
+	return nil
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// parseHexUint32 parses the optionally "0x"-prefixed hex values test files
+// use for register/memory/address fields, returning an error instead of
+// panicking (unlike addressToUint32) since a malformed fixture should fail
+// just that one test, not the whole `gecko test`/`go test` run.
+func parseHexUint32(value string) (uint32, error) {
+	v, err := strconv.ParseUint(strings.TrimPrefix(value, "0x"), 16, 32)
+	if err != nil {
+		return 0, fmt.Errorf("invalid hex value %q: %w", value, err)
+	}
+	return uint32(v), nil
+}
+
+// setRegister and getRegister resolve a test file's register name (r0-r31,
+// lr, ctr, xer) to the matching ppcsim.CPU field.
+func setRegister(cpu *ppcsim.CPU, name string, value uint32) error {
+	switch strings.ToLower(name) {
+	case "lr":
+		cpu.LR = value
+	case "ctr":
+		cpu.CTR = value
+	case "xer":
+		cpu.XER = value
+	default:
+		n, ok := gprIndex(name)
+		if !ok {
+			return fmt.Errorf("unknown register %q", name)
+		}
+		cpu.GPR[n] = value
+	}
+	return nil
+}
+
+func getRegister(cpu *ppcsim.CPU, name string) (uint32, error) {
+	switch strings.ToLower(name) {
+	case "lr":
+		return cpu.LR, nil
+	case "ctr":
+		return cpu.CTR, nil
+	case "xer":
+		return cpu.XER, nil
+	}
+	n, ok := gprIndex(name)
+	if !ok {
+		return 0, fmt.Errorf("unknown register %q", name)
+	}
+	return cpu.GPR[n], nil
+}
+
+func gprIndex(name string) (uint32, bool) {
+	name = strings.ToLower(name)
+	if !strings.HasPrefix(name, "r") {
+		return 0, false
+	}
+	n, err := strconv.Atoi(name[1:])
+	if err != nil || n < 0 || n > 31 {
+		return 0, false
+	}
+	return uint32(n), true
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// watchPollInterval is how often `gecko watch` checks watched files for
+// changes. Polling (rather than a notification API) keeps gecko dependency
+// free across platforms.
+const watchPollInterval = 500 * time.Millisecond
+
+// runWatch implements `gecko watch`: it rebuilds once immediately, then
+// watches codes.json, every Build entry's source file/folder, and any
+// .include-d files, rebuilding whenever one of them changes. Compiled asm
+// is cached in .gecko-cache (see cache.go), so an unattended rebuild only
+// pays the assembler cost for files that actually changed.
+func runWatch() {
+	fmt.Println("gecko: watching for changes (Ctrl+C to stop)...")
+
+	var lastMTimes map[string]time.Time
+
+	for {
+		func() {
+			defer func() {
+				if r := recover(); r != nil {
+					fmt.Fprintf(os.Stderr, "gecko: build failed: %v\n", r)
+				}
+			}()
+
+			config := readConfigFile()
+			watched := append([]string{"codes.json"}, collectWatchedFiles(config)...)
+			mtimes := snapshotMTimes(watched)
+
+			if mtimesEqual(mtimes, lastMTimes) {
+				return
+			}
+
+			start := time.Now()
+			runBuild(config)
+			fmt.Printf("gecko: rebuilt in %s\n", time.Since(start))
+			lastMTimes = mtimes
+		}()
+
+		time.Sleep(watchPollInterval)
+	}
+}
+
+// collectWatchedFiles returns every file a change to should trigger a
+// rebuild: each Inject/ReplaceCodeBlock/ExecuteAsm/ReplaceBinary source
+// file, every .asm file under an InjectFolder's SourceFolder (respecting
+// IsRecursive), and any file any of those pull in via `.include`.
+func collectWatchedFiles(config Config) []string {
+	var files []string
+	seenIncludes := map[string]bool{}
+
+	addSource := func(file string) {
+		if file == "" {
+			return
+		}
+		files = append(files, file)
+		seenIncludes[file] = true
+		files = append(files, resolveIncludes(file, seenIncludes)...)
+	}
+
+	for _, code := range config.Codes {
+		for _, geckoCode := range code.Build {
+			switch geckoCode.Type {
+			case Inject, ReplaceCodeBlock, ExecuteAsm, ReplaceBinary:
+				addSource(geckoCode.SourceFile)
+			case InjectFolder:
+				for _, job := range collectInjectionJobs(geckoCode.SourceFolder, geckoCode.IsRecursive) {
+					addSource(job.filePath)
+				}
+			}
+		}
+	}
+
+	if _, err := os.Stat("symbols.txt"); err == nil {
+		files = append(files, "symbols.txt")
+	}
+
+	if config.Library != nil {
+		for _, file := range collectAsmFilesInFolder(config.Library.SourceFolder) {
+			addSource(file)
+		}
+	}
+
+	return files
+}
+
+// snapshotMTimes records the current modification time of each watched
+// file. Files that fail to stat (e.g. a deleted/renamed source) are simply
+// left out, which snapshotMTimes treats as a change next poll.
+func snapshotMTimes(files []string) map[string]time.Time {
+	mtimes := make(map[string]time.Time, len(files))
+	for _, file := range files {
+		info, err := os.Stat(file)
+		if err != nil {
+			continue
+		}
+		mtimes[filepath.Clean(file)] = info.ModTime()
+	}
+	return mtimes
+}
+
+func mtimesEqual(a, b map[string]time.Time) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for file, mtime := range a {
+		if b[file] != mtime {
+			return false
+		}
+	}
+	return true
+}
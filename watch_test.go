@@ -0,0 +1,152 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// containsFile reports whether files contains path after Clean-ing both
+// sides, since collectWatchedFiles/resolveIncludes build paths with
+// filepath.Join while a test's expectation might be typed more loosely.
+func containsFile(files []string, path string) bool {
+	path = filepath.Clean(path)
+	for _, file := range files {
+		if filepath.Clean(file) == path {
+			return true
+		}
+	}
+	return false
+}
+
+// TestCollectWatchedFilesIncludesBuildSource checks that an Inject code's
+// SourceFile shows up in the watch list, so editing it triggers a rebuild.
+func TestCollectWatchedFilesIncludesBuildSource(t *testing.T) {
+	config := Config{
+		Codes: []CodeDescription{
+			{Build: []GeckoCode{{Type: Inject, SourceFile: "testdata/library_caller.asm"}}},
+		},
+	}
+
+	files := collectWatchedFiles(config)
+	if !containsFile(files, "testdata/library_caller.asm") {
+		t.Errorf("collectWatchedFiles(%v) = %v, want it to include testdata/library_caller.asm", config, files)
+	}
+}
+
+// TestCollectWatchedFilesIncludesInjectFolderFiles checks that every .asm
+// file under an InjectFolder's SourceFolder is watched, not just the folder
+// itself, since collectInjectionJobs is what actually discovers them.
+func TestCollectWatchedFilesIncludesInjectFolderFiles(t *testing.T) {
+	config := Config{
+		Codes: []CodeDescription{
+			{Build: []GeckoCode{{Type: InjectFolder, SourceFolder: "testdata/injectfolder"}}},
+		},
+	}
+
+	files := collectWatchedFiles(config)
+	for _, want := range []string{"testdata/injectfolder/a.asm", "testdata/injectfolder/b.asm", "testdata/injectfolder/c.asm"} {
+		if !containsFile(files, want) {
+			t.Errorf("collectWatchedFiles(%v) = %v, want it to include %s", config, files, want)
+		}
+	}
+}
+
+// TestCollectWatchedFilesIncludesIncludedFiles checks that a source file's
+// .include targets are pulled in transitively, so changing constants.inc
+// alone still triggers a rebuild of helper.asm.
+func TestCollectWatchedFilesIncludesIncludedFiles(t *testing.T) {
+	config := Config{
+		Codes: []CodeDescription{
+			{Build: []GeckoCode{{Type: Inject, SourceFile: "testdata/library/helper.asm"}}},
+		},
+	}
+
+	files := collectWatchedFiles(config)
+	if !containsFile(files, "testdata/library/constants.inc") {
+		t.Errorf("collectWatchedFiles(%v) = %v, want it to include testdata/library/constants.inc via .include", config, files)
+	}
+}
+
+// TestCollectWatchedFilesIncludesLibrary checks that every .asm file in a
+// configured Library's SourceFolder is watched, matching the auto-linked
+// region resolveLinkerSymbols builds from the same folder.
+func TestCollectWatchedFilesIncludesLibrary(t *testing.T) {
+	config := Config{Library: &LibraryConfig{Address: "0x80001800", SourceFolder: "testdata/library"}}
+
+	files := collectWatchedFiles(config)
+	if !containsFile(files, "testdata/library/helper.asm") {
+		t.Errorf("collectWatchedFiles(%v) = %v, want it to include testdata/library/helper.asm", config, files)
+	}
+}
+
+// TestCollectWatchedFilesIncludesSymbolsTxt checks that an on-disk
+// symbols.txt is watched when present, so a relink of a game symbol
+// triggers a rebuild even though no Build source named it directly.
+func TestCollectWatchedFilesIncludesSymbolsTxt(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "symbols.txt"), []byte("known_game_func = 0x80123456\n"), 0644); err != nil {
+		t.Fatalf("failed to write fixture: %v", err)
+	}
+
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("failed to get working directory: %v", err)
+	}
+	defer os.Chdir(wd)
+	if err := os.Chdir(dir); err != nil {
+		t.Fatalf("failed to chdir into fixture dir: %v", err)
+	}
+
+	files := collectWatchedFiles(Config{})
+	if !containsFile(files, "symbols.txt") {
+		t.Errorf("collectWatchedFiles should include symbols.txt when it exists, got %v", files)
+	}
+}
+
+// TestSnapshotMTimesSkipsMissingFiles checks that a file that can't be
+// stat'd (e.g. deleted since it was last watched) is simply left out of the
+// snapshot rather than failing it outright; mtimesEqual then naturally
+// treats that as a change on the next poll.
+func TestSnapshotMTimesSkipsMissingFiles(t *testing.T) {
+	dir := t.TempDir()
+	present := filepath.Join(dir, "present.asm")
+	if err := os.WriteFile(present, []byte("nop"), 0644); err != nil {
+		t.Fatalf("failed to write fixture: %v", err)
+	}
+	missing := filepath.Join(dir, "missing.asm")
+
+	mtimes := snapshotMTimes([]string{present, missing})
+	if len(mtimes) != 1 {
+		t.Fatalf("snapshotMTimes = %v, want exactly one entry (for %s)", mtimes, present)
+	}
+	if _, ok := mtimes[filepath.Clean(present)]; !ok {
+		t.Errorf("snapshotMTimes missing entry for %s", present)
+	}
+}
+
+func TestMTimesEqual(t *testing.T) {
+	now := time.Now()
+	later := now.Add(time.Minute)
+
+	tests := []struct {
+		name string
+		a, b map[string]time.Time
+		want bool
+	}{
+		{"both empty", map[string]time.Time{}, map[string]time.Time{}, true},
+		{"identical", map[string]time.Time{"a.asm": now}, map[string]time.Time{"a.asm": now}, true},
+		{"different mtime", map[string]time.Time{"a.asm": now}, map[string]time.Time{"a.asm": later}, false},
+		{"different file set, same length", map[string]time.Time{"a.asm": now}, map[string]time.Time{"b.asm": now}, false},
+		{"different length", map[string]time.Time{"a.asm": now}, map[string]time.Time{"a.asm": now, "b.asm": now}, false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := mtimesEqual(tc.a, tc.b); got != tc.want {
+				t.Errorf("mtimesEqual(%v, %v) = %v, want %v", tc.a, tc.b, got, tc.want)
+			}
+		})
+	}
+}